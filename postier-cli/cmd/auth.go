@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bouteillerAlan/postier/history"
+	"github.com/bouteillerAlan/postier/http"
+	"github.com/bouteillerAlan/postier/oauth"
+	"github.com/spf13/cobra"
+)
+
+// Initialize auth command
+func init() {
+	var authCmd = &cobra.Command{
+		Use:   "auth",
+		Short: "Manage OAuth2 logins",
+		Long:  "Manage the tokens of the OAuth2 profiles defined in oauth2.json",
+	}
+
+	var loginCmd = &cobra.Command{
+		Use:   "login <profile>",
+		Short: "Log in with an OAuth2 profile in the browser",
+		Long: "Run the authorization code flow with PKCE of an OAuth2 profile: open the printed URL, " +
+			"log in, and the authorization server sends the code back to a loopback listener. " +
+			"The tokens are cached for the requests sent with --oauth2 and renewed with their refresh token.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conn, err := readConnectionFlags(cmd)
+			if err != nil {
+				return err
+			}
+			tokens, err := oauth2TokenSource(args[0], &conn)
+			if err != nil {
+				return err
+			}
+
+			token, err := tokens.Login(cmd.Context(), func(authURL string) {
+				fmt.Printf("Open this URL in your browser to log in:\n\n  %s\n\nWaiting for the authorization server...\n", authURL)
+			})
+			if err != nil {
+				return err
+			}
+
+			path, _ := oauth.GetTokensFilePath()
+			expires := "no expiry given"
+			if !token.Expiry.IsZero() {
+				expires = "expires " + token.Expiry.Format(time.RFC3339)
+			}
+			fmt.Printf("Logged in with %s (%s), tokens saved to %s\n", args[0], expires, path)
+			return nil
+		},
+	}
+
+	authCmd.AddCommand(loginCmd)
+	RootCmd.AddCommand(authCmd)
+}
+
+// Read the credentials of the --auth and --auth-type flags. The returned reference is
+// what the history keeps instead of the secret: env:VAR, @file or the user name.
+func readAuth(cmd *cobra.Command) (*http.Auth, string, error) {
+	value, _ := cmd.Flags().GetString("auth")
+	authType, _ := cmd.Flags().GetString("auth-type")
+	if value == "" {
+		return nil, "", nil
+	}
+	return resolveAuth(authType, value)
+}
+
+// Rebuild the credentials of a history entry, the flags replace what they set. The secret is read
+// again from its reference, or asked on the terminal when it was given on the command line.
+func historyAuth(cmd *cobra.Command, entry *history.HistoryEntry) (*http.Auth, string, error) {
+	// An OAuth2 profile or an AWS signature given on the command line replaces the recorded credentials
+	if cmd.Flags().Changed("oauth2") || cmd.Flags().Changed("aws-sigv4") {
+		return nil, "", nil
+	}
+	value, authType := entry.AuthRef, entry.AuthType
+	if cmd.Flags().Changed("auth") {
+		value, _ = cmd.Flags().GetString("auth")
+	}
+	if cmd.Flags().Changed("auth-type") || authType == "" {
+		authType, _ = cmd.Flags().GetString("auth-type")
+	}
+	if value == "" && entry.AuthType == "" {
+		return nil, "", nil
+	}
+	return resolveAuth(authType, value)
+}
+
+// Resolve credentials given as user:pass (the token for bearer), env:VAR or @file.
+// A user name without password, or a missing token, is completed on the terminal.
+func resolveAuth(authType, value string) (*http.Auth, string, error) {
+	switch authType {
+	case http.AuthBasic, http.AuthBearer, http.AuthDigest:
+	default:
+		return nil, "", fmt.Errorf("unsupported auth type: %s", authType)
+	}
+
+	secret, ref, err := readSecretRef(value)
+	if err != nil {
+		return nil, "", err
+	}
+
+	auth := &http.Auth{Type: authType}
+	if authType == http.AuthBearer {
+		if secret == "" {
+			token, err := promptCredential("Enter bearer token: ")
+			if err != nil {
+				return nil, "", err
+			}
+			secret = token
+		}
+		auth.Password = secret
+		return auth, ref, nil
+	}
+
+	username, password, found := strings.Cut(secret, ":")
+	if username == "" {
+		return nil, "", fmt.Errorf("invalid credentials, expected user:pass")
+	}
+	if !found {
+		if password, err = promptCredential(fmt.Sprintf("Enter password for %s: ", username)); err != nil {
+			return nil, "", err
+		}
+	}
+	if ref == "" {
+		ref = username
+	}
+	auth.Username, auth.Password = username, password
+	return auth, ref, nil
+}
+
+// Read a secret given as env:VAR or @file, ref is the reference, empty when value is the secret itself
+func readSecretRef(value string) (secret, ref string, err error) {
+	switch {
+	case strings.HasPrefix(value, "env:"):
+		secret = os.Getenv(value[len("env:"):])
+		if secret == "" {
+			return "", "", fmt.Errorf("environment variable %s is empty", value[len("env:"):])
+		}
+		return secret, value, nil
+	case strings.HasPrefix(value, "@"):
+		data, err := os.ReadFile(value[1:])
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read credentials: %w", err)
+		}
+		return strings.TrimSpace(string(data)), value, nil
+	}
+	return value, "", nil
+}
+
+// Get the OAuth2 profile of a history entry, unless the flags give other credentials
+func historyOAuth2(cmd *cobra.Command, entry *history.HistoryEntry) string {
+	if cmd.Flags().Changed("oauth2") || cmd.Flags().Changed("auth") || cmd.Flags().Changed("aws-sigv4") {
+		profile, _ := cmd.Flags().GetString("oauth2")
+		return profile
+	}
+	return entry.OAuth2
+}
+
+// Get the AWS signing scope of a history entry, unless the flags give other credentials
+func historyAWSSigV4(cmd *cobra.Command, entry *history.HistoryEntry) string {
+	if cmd.Flags().Changed("aws-sigv4") || cmd.Flags().Changed("auth") || cmd.Flags().Changed("oauth2") {
+		scope, _ := cmd.Flags().GetString("aws-sigv4")
+		return scope
+	}
+	return entry.AWSSigV4
+}
+
+// Build the AWS signer of a region/service scope, the access keys are read from the
+// environment or the AWS profile files each time and never recorded
+func awsSigV4(scope string) (*http.AWSSigV4, error) {
+	if scope == "" {
+		return nil, nil
+	}
+	region, service, err := http.ParseAWSScope(scope)
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := http.LoadAWSCredentials()
+	if err != nil {
+		return nil, err
+	}
+	return &http.AWSSigV4{Region: region, Service: service, Credentials: *credentials}, nil
+}
+
+// Build the bearer credentials of an OAuth2 profile
+func oauth2Auth(name string, conn *connectionFlags) (*http.Auth, error) {
+	tokens, err := oauth2TokenSource(name, conn)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Auth{Type: http.AuthBearer, Tokens: tokens}, nil
+}
+
+// Get the token source of an OAuth2 profile. Its tokens are fetched with the
+// connection settings of the request, through a client of their own.
+func oauth2TokenSource(name string, conn *connectionFlags) (*oauth.TokenSource, error) {
+	profile, err := oauth.GetProfile(name)
+	if err != nil {
+		return nil, err
+	}
+	client, err := http.NewClient(conn.clientOptions()...)
+	if errors.Is(err, http.ErrPassphraseRequired) {
+		if err := promptPassphrase(conn); err != nil {
+			return nil, err
+		}
+		client, err = http.NewClient(conn.clientOptions()...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return oauth.NewTokenSource(name, profile, client), nil
+}
+
+// Ask for a password or token the command line and the history do not hold
+func promptCredential(prompt string) (string, error) {
+	secret, ok, err := readSecret(prompt)
+	if !ok {
+		return "", fmt.Errorf("credentials are incomplete and the standard input is not a terminal, use --auth")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read credentials: %w", err)
+	}
+	return secret, nil
+}
+
+// Record the auth scheme and the credential reference in a history entry. The
+// credentials of an OAuth2 profile are recorded as the profile name only.
+func recordAuth(entry *history.HistoryEntry, auth *http.Auth, ref string) {
+	if auth == nil || auth.Tokens != nil {
+		return
+	}
+	entry.AuthType = auth.Type
+	entry.AuthRef = ref
+}
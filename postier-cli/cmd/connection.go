@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bouteillerAlan/postier/history"
+	"github.com/bouteillerAlan/postier/http"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// connectionFlags holds the connection settings shared by the HTTP commands and replay
+type connectionFlags struct {
+	insecure bool
+	caCert   string
+	caPath   string
+	cert     http.ClientCert
+	version  string
+	redirect http.RedirectPolicy
+
+	connectTimeout        time.Duration
+	tlsTimeout            time.Duration
+	responseHeaderTimeout time.Duration
+	maxTime               time.Duration
+
+	retry http.RetryPolicy
+
+	proxy   string
+	noProxy string
+
+	unixSocket string
+	ipVersion  string
+	resolve    []string
+	connectTo  []string
+	dnsServers []string
+
+	compressed bool
+	cookieJar  string
+}
+
+// Read the connection settings from the command flags, the passphrase may be given as env:VAR or @file
+func readConnectionFlags(cmd *cobra.Command) (connectionFlags, error) {
+	var conn connectionFlags
+	conn.insecure, _ = cmd.Flags().GetBool("insecure")
+	conn.caCert, _ = cmd.Flags().GetString("cacert")
+	conn.caPath, _ = cmd.Flags().GetString("capath")
+	conn.cert.CertFile, _ = cmd.Flags().GetString("cert")
+	conn.cert.KeyFile, _ = cmd.Flags().GetString("key")
+	conn.cert.Type, _ = cmd.Flags().GetString("cert-type")
+	conn.version, _ = cmd.Flags().GetString("http")
+	if http3, _ := cmd.Flags().GetBool("http3"); http3 {
+		conn.version = http.HTTPVersion3
+	}
+	conn.redirect.Follow, _ = cmd.Flags().GetBool("follow")
+	if noFollow, _ := cmd.Flags().GetBool("no-follow"); noFollow {
+		conn.redirect.Follow = false
+	}
+	conn.redirect.MaxRedirects, _ = cmd.Flags().GetInt("max-redirects")
+	conn.redirect.KeepAuth, _ = cmd.Flags().GetBool("location-trusted")
+	conn.connectTimeout, _ = cmd.Flags().GetDuration("connect-timeout")
+	conn.tlsTimeout, _ = cmd.Flags().GetDuration("tls-timeout")
+	conn.responseHeaderTimeout, _ = cmd.Flags().GetDuration("response-header-timeout")
+	conn.maxTime, _ = cmd.Flags().GetDuration("max-time")
+	conn.retry.Retries, _ = cmd.Flags().GetInt("retry")
+	conn.retry.Delay, _ = cmd.Flags().GetDuration("retry-delay")
+	conn.retry.RetryAll, _ = cmd.Flags().GetBool("retry-all")
+	conn.retry.OnRetry = printRetry
+	conn.proxy, _ = cmd.Flags().GetString("proxy")
+	conn.noProxy, _ = cmd.Flags().GetString("noproxy")
+	conn.unixSocket, _ = cmd.Flags().GetString("unix-socket")
+	if ipv4, _ := cmd.Flags().GetBool("ipv4"); ipv4 {
+		conn.ipVersion = http.IPVersion4
+	}
+	if ipv6, _ := cmd.Flags().GetBool("ipv6"); ipv6 {
+		conn.ipVersion = http.IPVersion6
+	}
+	conn.resolve, _ = cmd.Flags().GetStringArray("resolve")
+	conn.connectTo, _ = cmd.Flags().GetStringArray("connect-to")
+	conn.dnsServers, _ = cmd.Flags().GetStringSlice("dns-servers")
+	conn.compressed, _ = cmd.Flags().GetBool("compressed")
+	conn.cookieJar, _ = cmd.Flags().GetString("cookie-jar")
+
+	passphrase, _ := cmd.Flags().GetString("pass")
+	var err error
+	if conn.cert.Passphrase, _, err = readSecretRef(passphrase); err != nil {
+		return conn, fmt.Errorf("failed to read the passphrase: %w", err)
+	}
+	return conn, nil
+}
+
+// Use the settings recorded in a history entry for every flag not given on the command line
+func (conn *connectionFlags) applyHistory(cmd *cobra.Command, entry *history.HistoryEntry) {
+	if !cmd.Flags().Changed("insecure") {
+		conn.insecure = entry.Insecure
+	}
+	if !cmd.Flags().Changed("cacert") {
+		conn.caCert = entry.CACert
+	}
+	if !cmd.Flags().Changed("capath") {
+		conn.caPath = entry.CAPath
+	}
+	if !cmd.Flags().Changed("http") && !cmd.Flags().Changed("http3") && entry.HTTPVersion != "" {
+		conn.version = entry.HTTPVersion
+	}
+	if !cmd.Flags().Changed("unix-socket") {
+		conn.unixSocket = entry.UnixSocket
+	}
+	if !cmd.Flags().Changed("ipv4") && !cmd.Flags().Changed("ipv6") {
+		conn.ipVersion = entry.IPVersion
+	}
+	if !cmd.Flags().Changed("resolve") {
+		conn.resolve = entry.Resolve
+	}
+	if !cmd.Flags().Changed("connect-to") {
+		conn.connectTo = entry.ConnectTo
+	}
+	if !cmd.Flags().Changed("dns-servers") {
+		conn.dnsServers = entry.DNSServers
+	}
+	if !cmd.Flags().Changed("compressed") {
+		conn.compressed = entry.Compressed
+	}
+	if !cmd.Flags().Changed("cookie-jar") {
+		conn.cookieJar = entry.CookieJar
+	}
+	// The passphrase is never recorded, it is asked again when needed
+	if !cmd.Flags().Changed("cert") {
+		conn.cert.CertFile = entry.CertFile
+		if !cmd.Flags().Changed("key") {
+			conn.cert.KeyFile = entry.KeyFile
+		}
+		if !cmd.Flags().Changed("cert-type") {
+			conn.cert.Type = entry.CertType
+		}
+	}
+}
+
+// Record the connection settings in a history entry
+func (conn connectionFlags) recordHistory(entry *history.HistoryEntry) {
+	entry.Insecure = conn.insecure
+	entry.CACert = conn.caCert
+	entry.CAPath = conn.caPath
+	entry.CertFile = conn.cert.CertFile
+	entry.KeyFile = conn.cert.KeyFile
+	if conn.cert.CertFile != "" {
+		entry.CertType = conn.cert.Type
+	}
+	entry.HTTPVersion = conn.version
+	entry.UnixSocket = conn.unixSocket
+	entry.IPVersion = conn.ipVersion
+	entry.Resolve = conn.resolve
+	entry.ConnectTo = conn.connectTo
+	entry.DNSServers = conn.dnsServers
+	entry.Compressed = conn.compressed
+	entry.CookieJar = conn.cookieJar
+}
+
+// Convert the connection settings to client options
+func (conn connectionFlags) clientOptions() []http.Option {
+	opts := []http.Option{
+		http.WithInsecure(conn.insecure),
+		http.WithCACert(conn.caCert),
+		http.WithCAPath(conn.caPath),
+		http.WithClientCert(conn.cert),
+		http.WithHTTPVersion(conn.version),
+		http.WithRedirectPolicy(conn.redirect),
+		http.WithConnectTimeout(conn.connectTimeout),
+		http.WithTLSTimeout(conn.tlsTimeout),
+		http.WithResponseHeaderTimeout(conn.responseHeaderTimeout),
+		http.WithTimeout(conn.maxTime),
+		http.WithRetryPolicy(conn.retry),
+		http.WithProxy(conn.proxy),
+		http.WithUnixSocket(conn.unixSocket),
+		http.WithIPVersion(conn.ipVersion),
+		http.WithResolve(conn.resolve...),
+		http.WithConnectTo(conn.connectTo...),
+		http.WithDNSServers(conn.dnsServers...),
+		http.WithCompressed(conn.compressed),
+	}
+	if conn.noProxy != "" {
+		opts = append(opts, http.WithNoProxy(conn.noProxy))
+	}
+	return opts
+}
+
+// Report a failed attempt before it is retried
+func printRetry(attempt http.Attempt, delay time.Duration) {
+	reason := attempt.Error
+	if reason == "" {
+		reason = fmt.Sprintf("HTTP %d", attempt.StatusCode)
+	}
+	if attempt.Timings != nil {
+		reason += fmt.Sprintf(" in %s", attempt.Timings.Total)
+	}
+	fmt.Fprintf(os.Stderr, "Attempt %d failed: %s, retrying in %s\n", attempt.Number, reason, delay.Round(time.Millisecond))
+}
+
+// Ask for the client key passphrase on the terminal
+func promptPassphrase(conn *connectionFlags) error {
+	passphrase, ok, err := readSecret(fmt.Sprintf("Enter passphrase for %s: ", conn.cert.CertFile))
+	if !ok {
+		return fmt.Errorf("%w, use --pass with the passphrase, env:VAR or @file", http.ErrPassphraseRequired)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read passphrase: %w", err)
+	}
+
+	conn.cert.Passphrase = passphrase
+	return nil
+}
+
+// Read a secret typed on the terminal without echoing it, ok is false when the standard input is not a terminal
+func readSecret(prompt string) (secret string, ok bool, err error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", false, nil
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	data, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return string(data), true, err
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bouteillerAlan/postier/http"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// Initialize cookies command
+func init() {
+	var cookiesCmd = &cobra.Command{
+		Use:   "cookies",
+		Short: "List the cookies of a cookie jar",
+		Long:  "List the cookies stored in the cookie jar given with --cookie-jar",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jar, err := loadCookieJarFlag(cmd)
+			if err != nil {
+				return err
+			}
+
+			cookies := jar.All()
+			if len(cookies) == 0 {
+				fmt.Printf("No cookies in %s.\n", jar.Path())
+				return nil
+			}
+
+			fmt.Printf("Cookies (%d entries):\n\n", len(cookies))
+			headerColor := color.New(color.FgHiBlue, color.Bold)
+			headerColor.Printf("%-30s %-20s %-25s %-30s %-25s %s\n", "DOMAIN", "PATH", "NAME", "VALUE", "EXPIRES", "FLAGS")
+			fmt.Println(strings.Repeat("-", 140))
+			for _, cookie := range cookies {
+				domain := cookie.Domain
+				if !cookie.HostOnly {
+					domain = "." + domain
+				}
+				value := cookie.Value
+				if len(value) > 30 {
+					value = value[:27] + "..."
+				}
+				expires := "session"
+				if cookie.Expires != 0 {
+					expires = time.Unix(cookie.Expires, 0).Format(time.RFC3339)
+				}
+				var flags []string
+				if cookie.Secure {
+					flags = append(flags, "secure")
+				}
+				if cookie.HttpOnly {
+					flags = append(flags, "httponly")
+				}
+				fmt.Printf("%-30s %-20s %-25s %-30s %-25s %s\n", domain, cookie.Path, cookie.Name, value, expires, strings.Join(flags, ","))
+			}
+
+			fmt.Printf("\nCookie jar: %s\n", jar.Path())
+			return nil
+		},
+	}
+
+	var clearCmd = &cobra.Command{
+		Use:   "clear [domain]",
+		Short: "Remove the cookies of a cookie jar",
+		Long:  "Remove every cookie of the cookie jar given with --cookie-jar, or only those of a domain and its subdomains",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jar, err := loadCookieJarFlag(cmd)
+			if err != nil {
+				return err
+			}
+
+			domain := ""
+			if len(args) == 1 {
+				domain = args[0]
+			}
+			removed := jar.Clear(domain)
+			if err := jar.Save(); err != nil {
+				return err
+			}
+			fmt.Printf("Removed %d cookies from %s\n", removed, jar.Path())
+			return nil
+		},
+	}
+
+	cookiesCmd.AddCommand(clearCmd)
+	RootCmd.AddCommand(cookiesCmd)
+}
+
+// Load the cookie jar given with --cookie-jar
+func loadCookieJarFlag(cmd *cobra.Command) (*http.CookieJar, error) {
+	path, _ := cmd.Flags().GetString("cookie-jar")
+	if path == "" {
+		return nil, fmt.Errorf("no cookie jar given, use --cookie-jar")
+	}
+	return http.LoadCookieJar(path)
+}
+
+// Names of the cookies given as name=value, what the history keeps of them
+func cookieNames(specs []string) []string {
+	var names []string
+	for _, spec := range specs {
+		name, _, _ := strings.Cut(spec, "=")
+		names = append(names, strings.TrimSpace(name))
+	}
+	return names
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/bouteillerAlan/postier/history"
 	"github.com/bouteillerAlan/postier/http"
+	"github.com/bouteillerAlan/postier/ui"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
@@ -25,18 +27,109 @@ func handleRequest(cmd *cobra.Command, args []string, method string) error {
 	query, _ := cmd.Flags().GetString("query")
 	body, _ := cmd.Flags().GetString("body")
 	bodyType, _ := cmd.Flags().GetString("body-type")
+	fields, _ := cmd.Flags().GetStringArray("field")
+	form, _ := cmd.Flags().GetStringArray("form")
+	compressBody, _ := cmd.Flags().GetString("compress-body")
+	cookies, _ := cmd.Flags().GetStringArray("cookie")
 	outputFile, _ := cmd.Flags().GetString("output")
 	verbose, _ := cmd.Flags().GetBool("verbose")
 	showProgress, _ := cmd.Flags().GetBool("progress")
+	conn, err := readConnectionFlags(cmd)
+	if err != nil {
+		return err
+	}
+	sig := readSignatureFlags(cmd)
+	oauth2Profile, _ := cmd.Flags().GetString("oauth2")
+	awsScope, _ := cmd.Flags().GetString("aws-sigv4")
+	sigV4, err := awsSigV4(awsScope)
+	if err != nil {
+		return err
+	}
+	auth, authRef, err := readAuth(cmd)
+	if err != nil {
+		return err
+	}
+
+	if len(fields) > 0 && len(form) > 0 {
+		return fmt.Errorf("-F builds a multipart body and --form a URL-encoded one, give only one of them")
+	}
+	// Multipart fields imply the multipart body type
+	if len(fields) > 0 && !cmd.Flags().Changed("body-type") {
+		bodyType = "multipart"
+	}
+	// Form fields imply the form body type
+	if len(form) > 0 && !cmd.Flags().Changed("body-type") {
+		bodyType = "form"
+	}
 
 	// Send HTTP request
-	resp, err := http.SendRequest(method, targetURL, headers, query, body, bodyType, showProgress)
+	client, err := newClient(cmd, &conn)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if oauth2Profile != "" {
+		if auth, err = oauth2Auth(oauth2Profile, &conn); err != nil {
+			return err
+		}
+	}
+	request := &http.Request{
+		Method:       method,
+		URL:          targetURL,
+		Headers:      headers,
+		Query:        query,
+		Body:         body,
+		BodyType:     bodyType,
+		Fields:       fields,
+		Form:         form,
+		CompressBody: compressBody,
+		Cookies:      cookies,
+		Auth:         auth,
+		SigV4:        sigV4,
+	}
+	if err := sig.apply(request); err != nil {
+		return err
+	}
+
+	// Stream the response body straight to the output file
+	output, err := createOutputFile(outputFile)
 	if err != nil {
 		return err
 	}
+	if output != nil {
+		request.Output = output
+	}
+	resp, err := client.Do(request)
+	saveCookies(client)
+	if err := closeOutputFile(output, err); err != nil {
+		return err
+	}
 
 	// Add to history
-	err = history.AddToHistory(method, targetURL, resp.StatusCode, resp.Time, resp.ContentLength, headers, query, body, bodyType)
+	entry := history.HistoryEntry{
+		Method:       method,
+		URL:          targetURL,
+		Status:       resp.StatusCode,
+		Protocol:     resp.Protocol,
+		Attempts:     len(resp.Attempts),
+		Duration:     resp.Time.String(),
+		Size:         resp.BodySize,
+		WireSize:     wireSize(resp),
+		Headers:      headers,
+		Query:        query,
+		Body:         body,
+		BodyType:     bodyType,
+		Fields:       fields,
+		Form:         form,
+		CompressBody: compressBody,
+		Cookies:      cookieNames(cookies),
+	}
+	conn.recordHistory(&entry)
+	sig.recordHistory(&entry)
+	recordAuth(&entry, auth, authRef)
+	entry.OAuth2 = oauth2Profile
+	entry.AWSSigV4 = awsScope
+	err = history.AddToHistory(entry)
 	if err != nil && verbose {
 		fmt.Fprintf(os.Stderr, "Warning: Failed to add to history: %s\n", err)
 	}
@@ -47,20 +140,92 @@ func handleRequest(cmd *cobra.Command, args []string, method string) error {
 	}
 
 	// Process response
-	printResponse(resp, verbose)
+	return writeResponse(cmd, resp)
+}
 
-	// Save response to file if requested
-	if outputFile != "" {
-		if err := saveResponseToFile(resp, outputFile); err != nil {
-			return fmt.Errorf("failed to save response to file: %w", err)
+// Build an HTTP client configured from the command flags and connection settings
+func newClient(cmd *cobra.Command, conn *connectionFlags) (*http.Client, error) {
+	showProgress, _ := cmd.Flags().GetBool("progress")
+	jsonOutput, _ := cmd.Flags().GetBool("json")
+
+	// Progress bars would corrupt the JSON document on stdout
+	if jsonOutput {
+		showProgress = false
+	}
+
+	opts := []http.Option{
+		http.WithProgress(ui.NewProgressDisplay(showProgress)),
+	}
+	if conn.cookieJar != "" {
+		jar, err := http.LoadCookieJar(conn.cookieJar)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, http.WithCookieJar(jar))
+	}
+
+	client, err := http.NewClient(append(opts, conn.clientOptions()...)...)
+	if errors.Is(err, http.ErrPassphraseRequired) {
+		if err := promptPassphrase(conn); err != nil {
+			return nil, err
+		}
+		client, err = http.NewClient(append(opts, conn.clientOptions()...)...)
+	}
+	return client, err
+}
+
+// Save the cookies set by the responses, even when the request failed after a redirect set some
+func saveCookies(client *http.Client) {
+	if err := client.SaveCookies(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+	}
+}
+
+// Write the HTTP response to the console in the format selected by the flags
+func writeResponse(cmd *cobra.Command, resp *http.Response) error {
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	jsonOutput, _ := cmd.Flags().GetBool("json")
+
+	if jsonOutput {
+		if err := printResponseJSON(resp); err != nil {
+			return err
 		}
+		return checkSignature(resp)
+	}
+	outputFile, _ := cmd.Flags().GetString("output")
+	printResponse(resp, verbose, outputFile)
+	return checkSignature(resp)
+}
+
+// Print the request body size before and after compression
+func printRequestCompression(compression *http.BodyCompression) {
+	fmt.Printf("Request Size: %d bytes (%d bytes sent with %s", compression.OriginalSize, compression.CompressedSize, compression.Encoding)
+	if compression.CompressedSize > 0 {
+		fmt.Printf(", ratio %.2f:1", float64(compression.OriginalSize)/float64(compression.CompressedSize))
 	}
+	fmt.Println(")")
+}
+
+// Size of a decoded response body as received, 0 when the body was not compressed
+func wireSize(resp *http.Response) int64 {
+	if resp.Encoding == "" {
+		return 0
+	}
+	return resp.WireSize
+}
 
+// Print the HTTP response to the console as JSON
+func printResponseJSON(resp *http.Response) error {
+	data, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+	fmt.Println(string(data))
 	return nil
 }
 
-// Print the HTTP response to the console
-func printResponse(resp *http.Response, verbose bool) {
+// Print the HTTP response to the console, the body is left out when it was saved to outputFile
+func printResponse(resp *http.Response, verbose bool, outputFile string) {
 	// Print status code with color based on status
 	statusColor := color.New(color.Bold)
 	switch {
@@ -77,8 +242,34 @@ func printResponse(resp *http.Response, verbose bool) {
 	// Print status and summary
 	fmt.Printf("HTTP Status: ")
 	statusColor.Printf("%d\n", resp.StatusCode)
+	fmt.Printf("Protocol: %s\n", resp.Protocol)
+	if resp.UnixSocket != "" {
+		fmt.Printf("Unix Socket: %s\n", resp.UnixSocket)
+	}
 	fmt.Printf("Response Time: %s\n", resp.Time)
-	fmt.Printf("Response Size: %d bytes\n", resp.ContentLength)
+	if resp.Encoding != "" {
+		fmt.Printf("Response Size: %d bytes (%d bytes on the wire with %s, ratio %.2f:1)\n",
+			resp.BodySize, resp.WireSize, resp.Encoding, float64(resp.BodySize)/float64(resp.WireSize))
+	} else {
+		fmt.Printf("Response Size: %d bytes\n", resp.BodySize)
+	}
+
+	if verbose && resp.RequestCompression != nil {
+		printRequestCompression(resp.RequestCompression)
+	}
+	if resp.Signature != nil {
+		printSignature(resp.Signature)
+	}
+
+	// Print the attempts when the request was retried
+	if len(resp.Attempts) > 1 {
+		printAttempts(resp)
+	}
+
+	// Print the redirect chain
+	if len(resp.Redirects) > 0 {
+		printRedirects(resp, verbose)
+	}
 
 	// Print detailed timing information
 	if resp.Timings != nil {
@@ -86,16 +277,43 @@ func printResponse(resp *http.Response, verbose bool) {
 		timingHeaders := color.New(color.FgHiBlue, color.Bold)
 		timingHeaders.Println("Phase                  Duration")
 		timingHeaders.Println("-----                  --------")
-		fmt.Printf("DNS Lookup:            %s\n", resp.Timings.DNSLookup)
-		fmt.Printf("TCP Connection:        %s\n", resp.Timings.TCPConnection)
-		if resp.Timings.TLSHandshake > 0 {
-			fmt.Printf("TLS Handshake:         %s\n", resp.Timings.TLSHandshake)
+		// No name is resolved to reach a Unix socket
+		if resp.UnixSocket == "" {
+			fmt.Printf("DNS Lookup:            %s\n", resp.Timings.DNSLookup)
+		}
+		if resp.Timings.QUICHandshake > 0 {
+			fmt.Printf("QUIC Handshake:        %s\n", resp.Timings.QUICHandshake)
+		} else {
+			if resp.UnixSocket != "" {
+				fmt.Printf("Socket Connection:     %s\n", resp.Timings.TCPConnection)
+			} else {
+				fmt.Printf("TCP Connection:        %s\n", resp.Timings.TCPConnection)
+			}
+			if resp.Timings.ProxyConnect > 0 {
+				fmt.Printf("Proxy Connect:         %s\n", resp.Timings.ProxyConnect)
+			}
+			if resp.Timings.TLSHandshake > 0 {
+				fmt.Printf("TLS Handshake:         %s\n", resp.Timings.TLSHandshake)
+			}
+		}
+		if resp.Timings.RequestWrite > 0 {
+			fmt.Printf("Request Write:         %s\n", resp.Timings.RequestWrite)
 		}
 		fmt.Printf("Server Processing:     %s\n", resp.Timings.ServerTime)
 		fmt.Printf("Content Transfer:      %s\n", resp.Timings.Transfer)
 		fmt.Printf("Total:                 %s\n", resp.Timings.Total)
 	}
 
+	// Print connection details if verbose
+	if verbose && resp.Connection != nil {
+		printConnectionInfo(resp.Connection)
+	}
+
+	// Print TLS details if verbose
+	if verbose && resp.TLS != nil {
+		printTLSInfo(resp.TLS)
+	}
+
 	// Print headers if verbose
 	if verbose {
 		fmt.Println("\nResponse Headers:")
@@ -110,6 +328,10 @@ func printResponse(resp *http.Response, verbose bool) {
 	}
 
 	// Print response body
+	if outputFile != "" {
+		fmt.Printf("\nResponse Body: saved to %s (%d bytes)\n", outputFile, resp.BodySize)
+		return
+	}
 	fmt.Println("\nResponse Body:")
 	// Try to pretty print JSON
 	if strings.Contains(resp.Headers["Content-Type"], "application/json") {
@@ -126,18 +348,133 @@ func printResponse(resp *http.Response, verbose bool) {
 	fmt.Println(resp.Body)
 }
 
-// Save response to a file
-func saveResponseToFile(resp *http.Response, filename string) error {
-	// Create output file
+// Print every attempt made before the final response
+func printAttempts(resp *http.Response) {
+	fmt.Printf("\nAttempts (%d):\n", len(resp.Attempts))
+	for _, attempt := range resp.Attempts {
+		fmt.Printf("[%d] ", attempt.Number)
+		if attempt.Error != "" {
+			color.New(color.FgRed).Printf("error")
+			fmt.Printf(" %s", attempt.Error)
+		} else {
+			fmt.Printf("%d", attempt.StatusCode)
+		}
+		if attempt.Timings != nil {
+			fmt.Printf(" in %s", attempt.Timings.Total)
+		}
+		if attempt.Delay > 0 {
+			fmt.Printf(", waited %s", attempt.Delay.Round(time.Millisecond))
+		}
+		fmt.Println()
+	}
+}
+
+// Print the redirects followed before the final response
+func printRedirects(resp *http.Response, verbose bool) {
+	fmt.Printf("\nRedirects (%d):\n", len(resp.Redirects))
+	hopColor := color.New(color.FgCyan)
+	for i, hop := range resp.Redirects {
+		fmt.Printf("[%d] ", i+1)
+		hopColor.Printf("%d", hop.StatusCode)
+		fmt.Printf(" %s\n    -> %s (%s)\n", hop.URL, hop.Location, hop.Timings.Total)
+		if verbose {
+			fmt.Printf("    DNS %s, Connect %s, TLS %s, Server %s\n",
+				hop.Timings.DNSLookup, hop.Timings.TCPConnection, hop.Timings.TLSHandshake, hop.Timings.ServerTime)
+		}
+	}
+	fmt.Printf("Final URL: %s\n", resp.URL)
+}
+
+// Print the addresses of the connection and how it was obtained
+func printConnectionInfo(info *http.ConnectionInfo) {
+	fmt.Println("\nConnection:")
+	if len(info.ResolvedAddrs) > 0 {
+		fmt.Printf("Resolved Addresses:    %s\n", strings.Join(info.ResolvedAddrs, ", "))
+	}
+	for _, attempt := range info.DialAttempts {
+		fmt.Printf("Dial %-5s %-22s ", attempt.Network, attempt.Addr)
+		if attempt.Error != "" {
+			color.New(color.FgRed).Printf("failed")
+			fmt.Printf(" in %s: %s\n", attempt.Duration, attempt.Error)
+		} else {
+			color.New(color.FgGreen).Printf("connected")
+			fmt.Printf(" in %s\n", attempt.Duration)
+		}
+	}
+	if info.LocalAddr != "" {
+		fmt.Printf("Local Address:         %s\n", info.LocalAddr)
+	}
+	if info.RemoteAddr != "" {
+		fmt.Printf("Remote Address:        %s\n", info.RemoteAddr)
+	}
+	switch {
+	case info.WasIdle:
+		fmt.Printf("Reused:                yes, idle for %s\n", info.IdleTime)
+	case info.Reused:
+		fmt.Printf("Reused:                yes\n")
+	default:
+		fmt.Printf("Reused:                no, new connection\n")
+	}
+}
+
+// Print the TLS connection details
+func printTLSInfo(info *http.TLSInfo) {
+	fmt.Println("\nTLS Connection:")
+	fmt.Printf("Version:               %s\n", info.Version)
+	fmt.Printf("Cipher Suite:          %s\n", info.CipherSuite)
+	if info.ALPN != "" {
+		fmt.Printf("ALPN Protocol:         %s\n", info.ALPN)
+	}
+	if info.ServerName != "" {
+		fmt.Printf("Server Name (SNI):     %s\n", info.ServerName)
+	}
+	fmt.Printf("Session Resumed:       %t\n", info.Resumed)
+	if info.OCSPStapled {
+		fmt.Printf("OCSP Stapling:         %s\n", info.OCSPStatus)
+	} else {
+		fmt.Printf("OCSP Stapling:         none\n")
+	}
+
+	fmt.Println("\nCertificate Chain:")
+	for i, cert := range info.PeerCertificates {
+		expiry := cert.NotAfter.Format(time.RFC3339)
+		if time.Now().After(cert.NotAfter) {
+			expiry = color.RedString("%s (expired)", expiry)
+		}
+		fmt.Printf("[%d] Subject:  %s\n", i, cert.Subject)
+		fmt.Printf("    Issuer:   %s\n", cert.Issuer)
+		if names := append(append([]string{}, cert.DNSNames...), cert.IPs...); len(names) > 0 {
+			fmt.Printf("    SANs:     %s\n", strings.Join(names, ", "))
+		}
+		fmt.Printf("    Expires:  %s\n", expiry)
+	}
+}
+
+// Create the file the response body is streamed to, nil when no output file is set
+func createOutputFile(filename string) (*os.File, error) {
+	if filename == "" {
+		return nil, nil
+	}
 	file, err := os.Create(filename)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to create output file: %w", err)
 	}
-	defer file.Close()
+	return file, nil
+}
 
-	// Write response body to file
-	_, err = file.WriteString(resp.Body)
-	return err
+// Close the output file once the request is done, an output file left empty by a failed request is removed
+func closeOutputFile(file *os.File, requestErr error) error {
+	if file == nil {
+		return requestErr
+	}
+	info, statErr := file.Stat()
+	if err := file.Close(); err != nil && requestErr == nil {
+		return fmt.Errorf("failed to save response to file: %w", err)
+	}
+	if requestErr != nil && statErr == nil && info.Size() == 0 {
+		os.Remove(file.Name())
+	}
+	return requestErr
 }
 
 // Initialize HTTP method commands
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/bouteillerAlan/postier/history"
 	"github.com/bouteillerAlan/postier/http"
@@ -32,9 +34,29 @@ func init() {
 			query, _ := cmd.Flags().GetString("query")
 			body, _ := cmd.Flags().GetString("body")
 			bodyType, _ := cmd.Flags().GetString("body-type")
+			fields, _ := cmd.Flags().GetStringArray("field")
+			form, _ := cmd.Flags().GetStringArray("form")
+			compressBody, _ := cmd.Flags().GetString("compress-body")
+			cookies, _ := cmd.Flags().GetStringArray("cookie")
 			outputFile, _ := cmd.Flags().GetString("output")
 			verbose, _ := cmd.Flags().GetBool("verbose")
-			showProgress, _ := cmd.Flags().GetBool("progress")
+			conn, err := readConnectionFlags(cmd)
+			if err != nil {
+				return err
+			}
+			conn.applyHistory(cmd, entry)
+			sig := readSignatureFlags(cmd)
+			sig.applyHistory(cmd, entry)
+			auth, authRef, err := historyAuth(cmd, entry)
+			if err != nil {
+				return err
+			}
+			oauth2Profile := historyOAuth2(cmd, entry)
+			awsScope := historyAWSSigV4(cmd, entry)
+			sigV4, err := awsSigV4(awsScope)
+			if err != nil {
+				return err
+			}
 
 			// Use original values from history if not overridden
 			if headers == "" {
@@ -50,30 +72,93 @@ func init() {
 				// Only override if default value not changed
 				bodyType = entry.BodyType
 			}
+			// Fields given again replace the saved ones, multipart and form fields alike
+			if len(fields) == 0 && len(form) == 0 {
+				fields = entry.Fields
+				form = entry.Form
+			}
+			if !cmd.Flags().Changed("compress-body") {
+				compressBody = entry.CompressBody
+			}
+			// Cookie values are not kept in history, they are only sent when given again
+			if len(cookies) == 0 && len(entry.Cookies) > 0 {
+				fmt.Fprintf(os.Stderr, "Warning: cookies %s are not replayed, give them again with --cookie\n", strings.Join(cookieNames(entry.Cookies), ", "))
+			}
 
 			// Send HTTP request
-			resp, err := http.SendRequest(entry.Method, entry.URL, headers, query, body, bodyType, showProgress)
+			client, err := newClient(cmd, &conn)
 			if err != nil {
 				return err
 			}
+			defer client.Close()
+			if oauth2Profile != "" {
+				if auth, err = oauth2Auth(oauth2Profile, &conn); err != nil {
+					return err
+				}
+			}
+			request := &http.Request{
+				Method:       entry.Method,
+				URL:          entry.URL,
+				Headers:      headers,
+				Query:        query,
+				Body:         body,
+				BodyType:     bodyType,
+				Fields:       fields,
+				Form:         form,
+				CompressBody: compressBody,
+				Cookies:      cookies,
+				Auth:         auth,
+				SigV4:        sigV4,
+			}
+			if err := sig.apply(request); err != nil {
+				return err
+			}
+
+			// Stream the response body straight to the output file
+			output, err := createOutputFile(outputFile)
+			if err != nil {
+				return err
+			}
+			if output != nil {
+				request.Output = output
+			}
+			resp, err := client.Do(request)
+			saveCookies(client)
+			if err := closeOutputFile(output, err); err != nil {
+				return err
+			}
 
 			// Add the replayed request to history
-			err = history.AddToHistory(entry.Method, entry.URL, resp.StatusCode, resp.Time, resp.ContentLength, headers, query, body, bodyType)
+			replayed := history.HistoryEntry{
+				Method:       entry.Method,
+				URL:          entry.URL,
+				Status:       resp.StatusCode,
+				Protocol:     resp.Protocol,
+				Attempts:     len(resp.Attempts),
+				Duration:     resp.Time.String(),
+				Size:         resp.BodySize,
+				WireSize:     wireSize(resp),
+				Headers:      headers,
+				Query:        query,
+				Body:         body,
+				BodyType:     bodyType,
+				Fields:       fields,
+				Form:         form,
+				CompressBody: compressBody,
+				Cookies:      cookieNames(cookies),
+			}
+			conn.recordHistory(&replayed)
+			sig.recordHistory(&replayed)
+			recordAuth(&replayed, auth, authRef)
+			replayed.OAuth2 = oauth2Profile
+			replayed.AWSSigV4 = awsScope
+			err = history.AddToHistory(replayed)
 			if err != nil && verbose {
 				fmt.Printf("Warning: Failed to add replayed request to history: %s\n", err)
 			}
 
 			// Process response
-			printResponse(resp, verbose)
-
-			// Save response to file if requested
-			if outputFile != "" {
-				if err := saveResponseToFile(resp, outputFile); err != nil {
-					return fmt.Errorf("failed to save response to file: %w", err)
-				}
-			}
-
-			return nil
+			return writeResponse(cmd, resp)
 		},
 	}
 
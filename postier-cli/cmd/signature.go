@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bouteillerAlan/postier/history"
+	"github.com/bouteillerAlan/postier/http"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// signatureFlags holds the HTTP message signature settings shared by the HTTP commands and replay.
+// The keys are kept as their @file or env:VAR reference and read when the request is sent.
+type signatureFlags struct {
+	key          string
+	algorithm    string
+	keyID        string
+	components   []string
+	verifyKey    string
+	verifyMaxAge time.Duration
+}
+
+// Read the message signature settings from the command flags
+func readSignatureFlags(cmd *cobra.Command) signatureFlags {
+	var sig signatureFlags
+	sig.key, _ = cmd.Flags().GetString("sign-key")
+	sig.algorithm, _ = cmd.Flags().GetString("sign-alg")
+	sig.keyID, _ = cmd.Flags().GetString("sign-keyid")
+	sig.components, _ = cmd.Flags().GetStringSlice("sign-components")
+	sig.verifyKey, _ = cmd.Flags().GetString("verify-key")
+	sig.verifyMaxAge, _ = cmd.Flags().GetDuration("verify-max-age")
+	return sig
+}
+
+// Use the settings recorded in a history entry for every flag not given on the command line
+func (sig *signatureFlags) applyHistory(cmd *cobra.Command, entry *history.HistoryEntry) {
+	if !cmd.Flags().Changed("sign-key") {
+		sig.key = entry.SignKey
+		if !cmd.Flags().Changed("sign-alg") {
+			sig.algorithm = entry.SignAlg
+		}
+		if !cmd.Flags().Changed("sign-keyid") {
+			sig.keyID = entry.SignKeyID
+		}
+		if !cmd.Flags().Changed("sign-components") {
+			sig.components = entry.SignCovered
+		}
+	}
+	if !cmd.Flags().Changed("verify-key") {
+		sig.verifyKey = entry.VerifyKey
+	}
+}
+
+// Record the message signature settings in a history entry
+func (sig signatureFlags) recordHistory(entry *history.HistoryEntry) {
+	entry.SignKey = sig.key
+	if sig.key != "" {
+		entry.SignAlg = sig.algorithm
+		entry.SignKeyID = sig.keyID
+		entry.SignCovered = sig.components
+	}
+	entry.VerifyKey = sig.verifyKey
+}
+
+// Load the keys and set the message signature and the response verification of the request
+func (sig signatureFlags) apply(request *http.Request) error {
+	if sig.key != "" {
+		key, err := http.LoadSignatureKey(sig.key, sig.algorithm)
+		if err != nil {
+			return err
+		}
+		key.ID = sig.keyID
+		request.Signature = &http.MessageSignature{Key: key, Components: sig.components}
+	}
+	if sig.verifyKey != "" {
+		key, err := http.LoadSignatureKey(sig.verifyKey, "")
+		if err != nil {
+			return err
+		}
+		request.VerifyKey = key
+		request.VerifyMaxAge = sig.verifyMaxAge
+	}
+	return nil
+}
+
+// Print the outcome of the response signature verification
+func printSignature(verification *http.SignatureVerification) {
+	if !verification.Verified {
+		color.New(color.FgRed, color.Bold).Printf("Signature: not verified, %s\n", verification.Error)
+		return
+	}
+	color.New(color.FgGreen, color.Bold).Print("Signature: verified")
+	fmt.Printf(" %s (%s", verification.Label, verification.Algorithm)
+	if verification.KeyID != "" {
+		fmt.Printf(", keyid %s", verification.KeyID)
+	}
+	fmt.Println(")")
+}
+
+// Fail the command when the response signature could not be verified
+func checkSignature(resp *http.Response) error {
+	if resp.Signature != nil && !resp.Signature.Verified {
+		return fmt.Errorf("response signature verification failed: %s", resp.Signature.Error)
+	}
+	return nil
+}
@@ -1,28 +1,58 @@
 package history
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"time"
-	"crypto/rand"
-	"encoding/hex"
 )
 
 // HistoryEntry represents a single HTTP request entry in the history
 type HistoryEntry struct {
-	ID        string    `json:"id"`
-	Timestamp time.Time `json:"timestamp"`
-	Method    string    `json:"method"`
-	URL       string    `json:"url"`
-	Status    int       `json:"status"`
-	Duration  string    `json:"duration"`
-	Size      int64     `json:"size"`
-	Headers   string    `json:"headers,omitempty"`   // JSON string of request headers
-	Query     string    `json:"query,omitempty"`     // JSON string of query parameters
-	Body      string    `json:"body,omitempty"`      // Request body content
-	BodyType  string    `json:"body_type,omitempty"` // Type of the body content
+	ID           string    `json:"id"`
+	Timestamp    time.Time `json:"timestamp"`
+	Method       string    `json:"method"`
+	URL          string    `json:"url"`
+	Status       int       `json:"status"`
+	Duration     string    `json:"duration"`
+	Size         int64     `json:"size"`                    // Size of the response body, after content decoding
+	WireSize     int64     `json:"wire_size,omitempty"`     // Size of the response body as received, when it was compressed
+	Headers      string    `json:"headers,omitempty"`       // JSON string of request headers
+	Query        string    `json:"query,omitempty"`         // JSON string of query parameters
+	Body         string    `json:"body,omitempty"`          // Request body content
+	BodyType     string    `json:"body_type,omitempty"`     // Type of the body content
+	Fields       []string  `json:"fields,omitempty"`        // Multipart fields as given, file parts keep the path and not the content
+	Form         []string  `json:"form,omitempty"`          // Form fields as key=value
+	CompressBody string    `json:"compress_body,omitempty"` // Content coding applied to the body: gzip, zstd or deflate
+	Cookies      []string  `json:"cookies,omitempty"`       // Names of the one-off cookies given with --cookie, never their values
+	CookieJar    string    `json:"cookie_jar,omitempty"`    // Path to the cookie jar, never its content
+	AuthType     string    `json:"auth_type,omitempty"`     // Scheme of --auth: basic, bearer or digest
+	AuthRef      string    `json:"auth_ref,omitempty"`      // Where the credentials come from: env:VAR, @file or the user name, never the secret
+	OAuth2       string    `json:"oauth2,omitempty"`        // OAuth2 profile the bearer token came from, never the token
+	AWSSigV4     string    `json:"aws_sigv4,omitempty"`     // Region/service the request was signed for, the keys are read again on replay
+	SignKey      string    `json:"sign_key,omitempty"`      // Where the message signature key comes from: @file or env:VAR, never the key
+	SignAlg      string    `json:"sign_alg,omitempty"`      // Message signature algorithm given with --sign-alg
+	SignKeyID    string    `json:"sign_keyid,omitempty"`    // Key id of the message signature
+	SignCovered  []string  `json:"sign_covered,omitempty"`  // Components covered by the message signature
+	VerifyKey    string    `json:"verify_key,omitempty"`    // Where the key verifying the response signature comes from
+	Insecure     bool      `json:"insecure,omitempty"`      // Server certificate verification was disabled
+	CACert       string    `json:"ca_cert,omitempty"`       // Path to the custom CA bundle
+	CAPath       string    `json:"ca_path,omitempty"`       // Path to the custom CA directory
+	CertFile     string    `json:"cert_file,omitempty"`     // Path to the client certificate, never the key material
+	KeyFile      string    `json:"key_file,omitempty"`      // Path to the client private key
+	CertType     string    `json:"cert_type,omitempty"`     // Type of the client certificate: pem or p12
+	HTTPVersion  string    `json:"http_version,omitempty"`  // HTTP version requested: auto, 1.1, 2, h2c or 3
+	Protocol     string    `json:"protocol,omitempty"`      // Protocol actually negotiated, e.g. HTTP/2.0
+	Attempts     int       `json:"attempts,omitempty"`      // Number of attempts, retries included
+	UnixSocket   string    `json:"unix_socket,omitempty"`   // Unix socket the request was sent over
+	IPVersion    string    `json:"ip_version,omitempty"`    // IP version forced with -4 or -6
+	Resolve      []string  `json:"resolve,omitempty"`       // Host addresses pinned with --resolve
+	ConnectTo    []string  `json:"connect_to,omitempty"`    // Connection redirects given with --connect-to
+	DNSServers   []string  `json:"dns_servers,omitempty"`   // DNS servers used instead of the system ones
+	Compressed   bool      `json:"compressed,omitempty"`    // Every supported response encoding was accepted with --compressed
 }
 
 // GenerateID generates a random unique ID for history entries
@@ -52,8 +82,8 @@ func GetHistoryFilePath() (string, error) {
 	return filepath.Join(appDir, "history.txt"), nil
 }
 
-// AddToHistory adds a new entry to the history file
-func AddToHistory(method, url string, status int, duration time.Duration, size int64, headers, query, body, bodyType string) error {
+// AddToHistory adds a new entry to the history file, its ID and timestamp are generated
+func AddToHistory(entry HistoryEntry) error {
 	historyFilePath, err := GetHistoryFilePath()
 	if err != nil {
 		return err
@@ -64,19 +94,8 @@ func AddToHistory(method, url string, status int, duration time.Duration, size i
 	if err != nil {
 		return err
 	}
-	entry := HistoryEntry{
-		ID:        id,
-		Timestamp: time.Now(),
-		Method:    method,
-		URL:       url,
-		Status:    status,
-		Duration:  duration.String(),
-		Size:      size,
-		Headers:   headers,
-		Query:     query,
-		Body:      body,
-		BodyType:  bodyType,
-	}
+	entry.ID = id
+	entry.Timestamp = time.Now()
 
 	// Marshal entry to JSON
 	entryJSON, err := json.Marshal(entry)
@@ -0,0 +1,305 @@
+package http
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Authentication schemes accepted by Auth.Type
+const (
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+	AuthDigest = "digest"
+)
+
+// Auth holds the credentials the client turns into an Authorization header
+type Auth struct {
+	Type     string // basic, bearer or digest
+	Username string // User name for basic and digest
+	Password string // Password for basic and digest, the token for bearer
+
+	// Tokens supplies the bearer token instead of Password. A new token is asked
+	// once when the server rejects the request with 401.
+	Tokens TokenSource
+}
+
+// TokenSource supplies bearer tokens, such as an OAuth2 token cache
+type TokenSource interface {
+	// Token returns a valid token, refresh asks for a new one after the server rejected the last one
+	Token(ctx context.Context, refresh bool) (string, error)
+}
+
+// validate checks the auth type
+func (a *Auth) validate() error {
+	switch a.Type {
+	case AuthBasic, AuthBearer, AuthDigest:
+		return nil
+	}
+	return fmt.Errorf("unsupported auth type: %s", a.Type)
+}
+
+// authorize sets the Authorization header of a basic or bearer request. Digest
+// requests are sent without it and answered when the server sends its challenge.
+func (a *Auth) authorize(ctx context.Context, req *http.Request) error {
+	switch a.Type {
+	case AuthBasic:
+		req.SetBasicAuth(a.Username, a.Password)
+	case AuthBearer:
+		token, err := a.bearerToken(ctx, false)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// bearerToken returns the bearer token, from the token source when there is one
+func (a *Auth) bearerToken(ctx context.Context, refresh bool) (string, error) {
+	if a.Tokens == nil {
+		return a.Password, nil
+	}
+	// The token source sends requests of its own, which must not carry the trace and
+	// the credentials of the request being authorized
+	tokenCtx, cancel := context.WithCancel(context.Background())
+	stop := context.AfterFunc(ctx, cancel)
+	defer func() {
+		stop()
+		cancel()
+	}()
+	token, err := a.Tokens.Token(tokenCtx, refresh)
+	if err != nil {
+		return "", fmt.Errorf("failed to get bearer token: %w", err)
+	}
+	return token, nil
+}
+
+// answersUnauthorized reports whether a 401 response is answered with a second request
+func (a *Auth) answersUnauthorized() bool {
+	return a.Type == AuthDigest || (a.Type == AuthBearer && a.Tokens != nil)
+}
+
+// authTargetKey is the context key of the credentials answering 401 responses
+type authTargetKey struct{}
+
+// authTarget holds the credentials of a request and the host they were given for
+type authTarget struct {
+	auth *Auth
+	host string
+}
+
+// withAuthTarget adds the credentials for host to the request context
+func withAuthTarget(ctx context.Context, auth *Auth, host string) context.Context {
+	return context.WithValue(ctx, authTargetKey{}, &authTarget{auth: auth, host: host})
+}
+
+// authTransport answers the 401 responses of the requests carrying credentials: digest
+// challenges, and rejected bearer tokens that the token source can renew. The hosts
+// reached through redirects are only answered with keepAuth.
+type authTransport struct {
+	next     http.RoundTripper
+	keepAuth bool
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, _ := req.Context().Value(authTargetKey{}).(*authTarget)
+	if target == nil || (req.URL.Host != target.host && !t.keepAuth) {
+		return t.next.RoundTrip(req)
+	}
+	// An Authorization header given by the user is left alone
+	if target.auth.Type == AuthDigest && req.Header.Get("Authorization") != "" {
+		return t.next.RoundTrip(req)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	// A body read from the standard input cannot be sent a second time
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	if hasBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	var authorization string
+	if target.auth.Type == AuthDigest {
+		challenge := parseDigestChallenge(resp.Header.Values("Www-Authenticate"))
+		if challenge == nil {
+			return resp, nil
+		}
+		authorization, err = challenge.authorization(target.auth, req.Method, req.URL.RequestURI())
+	} else {
+		var token string
+		token, err = target.auth.bearerToken(req.Context(), true)
+		authorization = "Bearer " + token
+	}
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	retry := req.Clone(req.Context())
+	if hasBody {
+		if retry.Body, err = req.GetBody(); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+	}
+	retry.Header.Set("Authorization", authorization)
+
+	// Drain the rejected response so its connection can carry the second request
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	return t.next.RoundTrip(retry)
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped transport
+func (t *authTransport) CloseIdleConnections() {
+	closeIdleConnections(t.next)
+}
+
+// digestChallenge is the WWW-Authenticate Digest challenge of a server (RFC 7616)
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string // auth when the server supports it, empty for the RFC 2069 compatibility mode
+}
+
+// parseDigestChallenge picks the strongest digest challenge the client supports among
+// the WWW-Authenticate headers, nil when there is none
+func parseDigestChallenge(headers []string) *digestChallenge {
+	var best *digestChallenge
+	for _, header := range headers {
+		scheme, params, _ := strings.Cut(strings.TrimSpace(header), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		values := parseAuthParams(params)
+		challenge := &digestChallenge{
+			realm:     values["realm"],
+			nonce:     values["nonce"],
+			opaque:    values["opaque"],
+			algorithm: strings.ToUpper(values["algorithm"]),
+		}
+		if challenge.algorithm == "" {
+			challenge.algorithm = "MD5"
+		}
+		if digestHash(challenge.algorithm) == nil || challenge.nonce == "" {
+			continue
+		}
+		if qop, ok := values["qop"]; ok {
+			for _, option := range strings.Split(qop, ",") {
+				if strings.TrimSpace(option) == "auth" {
+					challenge.qop = "auth"
+				}
+			}
+			// Only auth-int is offered, it would need a hash of the whole body
+			if challenge.qop == "" {
+				continue
+			}
+		}
+		if best == nil || strings.HasPrefix(challenge.algorithm, "SHA-256") {
+			best = challenge
+		}
+	}
+	return best
+}
+
+// parseAuthParams parses the comma separated key=value parameters of a challenge,
+// values may be quoted strings
+func parseAuthParams(params string) map[string]string {
+	values := make(map[string]string)
+	for params != "" {
+		params = strings.TrimLeft(params, " \t,")
+		key, rest, found := strings.Cut(params, "=")
+		if !found {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimLeft(rest, " \t")
+
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			params = rest[min(i+1, len(rest)):]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value.WriteString(strings.TrimSpace(rest[:end]))
+			params = rest[end:]
+		}
+		values[key] = value.String()
+	}
+	return values
+}
+
+// digestHash returns the hash function of a digest algorithm, nil when it is not supported
+func digestHash(algorithm string) func() hash.Hash {
+	switch strings.TrimSuffix(algorithm, "-SESS") {
+	case "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	}
+	return nil
+}
+
+// authorization computes the Authorization header answering the challenge
+func (c *digestChallenge) authorization(auth *Auth, method, uri string) (string, error) {
+	cnonceBytes := make([]byte, 16)
+	if _, err := rand.Read(cnonceBytes); err != nil {
+		return "", fmt.Errorf("failed to generate digest cnonce: %w", err)
+	}
+	return c.authorizationWithCnonce(auth, method, uri, hex.EncodeToString(cnonceBytes)), nil
+}
+
+// authorizationWithCnonce computes the Authorization header with the given client nonce.
+// Each challenge is answered once, the nonce count is always 1.
+func (c *digestChallenge) authorizationWithCnonce(auth *Auth, method, uri, cnonce string) string {
+	newHash := digestHash(c.algorithm)
+	h := func(data string) string {
+		hasher := newHash()
+		io.WriteString(hasher, data)
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+	nc := "00000001"
+
+	ha1 := h(auth.Username + ":" + c.realm + ":" + auth.Password)
+	if strings.HasSuffix(c.algorithm, "-SESS") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+	response := h(ha1 + ":" + c.nonce + ":" + ha2)
+	if c.qop != "" {
+		response = h(ha1 + ":" + c.nonce + ":" + nc + ":" + cnonce + ":" + c.qop + ":" + ha2)
+	}
+
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace
+	header := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", algorithm=%s, response="%s"`,
+		quote(auth.Username), quote(c.realm), quote(c.nonce), quote(uri), c.algorithm, response)
+	if c.qop != "" {
+		header += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, c.qop, nc, cnonce)
+	}
+	if c.opaque != "" {
+		header += fmt.Sprintf(`, opaque="%s"`, quote(c.opaque))
+	}
+	return header
+}
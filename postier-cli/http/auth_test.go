@@ -0,0 +1,129 @@
+package http
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Example of RFC 7616 section 3.9.1
+const (
+	rfc7616Username = "Mufasa"
+	rfc7616Password = "Circle of Life"
+	rfc7616Cnonce   = "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ"
+	rfc7616Params   = `realm="http-auth@example.org", qop="auth, auth-int", ` +
+		`nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`
+)
+
+func TestDigestRFC7616(t *testing.T) {
+	auth := &Auth{Type: AuthDigest, Username: rfc7616Username, Password: rfc7616Password}
+	for _, test := range []struct {
+		algorithm string
+		response  string
+	}{
+		{"MD5", "8ca523f5e9506fed4657c9700eebdbec"},
+		{"SHA-256", "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"},
+	} {
+		t.Run(test.algorithm, func(t *testing.T) {
+			challenge := parseDigestChallenge([]string{"Digest " + rfc7616Params + ", algorithm=" + test.algorithm})
+			if challenge == nil {
+				t.Fatal("challenge not parsed")
+			}
+			header := challenge.authorizationWithCnonce(auth, "GET", "/dir/index.html", rfc7616Cnonce)
+			params := parseAuthParams(strings.TrimPrefix(header, "Digest "))
+			want := map[string]string{
+				"username":  rfc7616Username,
+				"realm":     "http-auth@example.org",
+				"uri":       "/dir/index.html",
+				"algorithm": test.algorithm,
+				"qop":       "auth",
+				"nc":        "00000001",
+				"cnonce":    rfc7616Cnonce,
+				"response":  test.response,
+				"opaque":    "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS",
+			}
+			for key, value := range want {
+				if params[key] != value {
+					t.Errorf("%s: got %q, want %q", key, params[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestDigestPrefersSHA256(t *testing.T) {
+	challenge := parseDigestChallenge([]string{
+		"Digest " + rfc7616Params + ", algorithm=SHA-256",
+		"Digest " + rfc7616Params + ", algorithm=MD5",
+	})
+	if challenge == nil || challenge.algorithm != "SHA-256" {
+		t.Fatalf("got %+v, want the SHA-256 challenge", challenge)
+	}
+}
+
+// verifyDigest checks an Authorization header the way a server does
+func verifyDigest(header, method, username, password string) bool {
+	params := parseAuthParams(strings.TrimPrefix(header, "Digest "))
+	var newHash func() hash.Hash = md5.New
+	if strings.HasPrefix(params["algorithm"], "SHA-256") {
+		newHash = sha256.New
+	}
+	h := func(data string) string {
+		hasher := newHash()
+		io.WriteString(hasher, data)
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+	ha1 := h(username + ":" + params["realm"] + ":" + password)
+	if strings.HasSuffix(params["algorithm"], "-sess") || strings.HasSuffix(params["algorithm"], "-SESS") {
+		ha1 = h(ha1 + ":" + params["nonce"] + ":" + params["cnonce"])
+	}
+	ha2 := h(method + ":" + params["uri"])
+	want := h(strings.Join([]string{ha1, params["nonce"], params["nc"], params["cnonce"], params["qop"], ha2}, ":"))
+	return params["username"] == username && params["nc"] == "00000001" && params["cnonce"] != "" && params["response"] == want
+}
+
+func TestDigestRetry(t *testing.T) {
+	for _, algorithm := range []string{"MD5", "MD5-sess", "SHA-256", "SHA-256-sess"} {
+		t.Run(algorithm, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				body, _ := io.ReadAll(r.Body)
+				authorization := r.Header.Get("Authorization")
+				if authorization == "" {
+					w.Header().Set("WWW-Authenticate", `Digest realm="test", qop="auth", nonce="abc123", algorithm=`+algorithm)
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				if !verifyDigest(authorization, r.Method, "user", "secret") || string(body) != `{"a":1}` {
+					w.WriteHeader(http.StatusForbidden)
+				}
+			}))
+			defer server.Close()
+
+			client, err := NewClient()
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(&Request{
+				Method:   "POST",
+				URL:      server.URL + "/dir/index.html?x=1",
+				Body:     `{"a":1}`,
+				BodyType: "json",
+				Auth:     &Auth{Type: AuthDigest, Username: "user", Password: "secret"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK || requests != 2 {
+				t.Errorf("got %d after %d requests, want 200 after 2", resp.StatusCode, requests)
+			}
+		})
+	}
+}
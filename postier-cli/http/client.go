@@ -3,35 +3,47 @@ package http
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"net/http/httptrace"
 	"net/url"
 	"os"
 	"strings"
 	"time"
-
-	"github.com/bouteillerAlan/postier/ui"
 )
 
 // Response represents a formatted HTTP response
 type Response struct {
 	StatusCode    int               `json:"status_code"`
+	Protocol      string            `json:"protocol"`
 	Headers       map[string]string `json:"headers"`
-	Body          string            `json:"body"`
+	Body          string            `json:"body"`      // Empty when the body was streamed to Request.Output
+	BodySize      int64             `json:"body_size"` // Bytes of body read, after content decoding
 	ContentLength int64             `json:"content_length"`
+	WireSize      int64             `json:"wire_size"`                  // Bytes of body received, before content decoding
+	Encoding      string            `json:"content_encoding,omitempty"` // Content codings removed from the body, empty when it was not decoded
 	Time          time.Duration     `json:"time"`
-	Timings       *HTTPTimings      `json:"timings,omitempty"`
+	Timings       *HTTPTimings      `json:"timings,omitempty"` // Timings of the final hop, Total covers the whole redirect chain
+	TLS           *TLSInfo          `json:"tls,omitempty"`
+	URL           string            `json:"url"`                   // Final URL, after redirects
+	Redirects     []RedirectHop     `json:"redirects,omitempty"`   // Redirects followed before the final response
+	Attempts      []Attempt         `json:"attempts,omitempty"`    // Every attempt made, the last one produced this response
+	UnixSocket    string            `json:"unix_socket,omitempty"` // Unix socket the request was sent over
+	Connection    *ConnectionInfo   `json:"connection,omitempty"`  // Addresses and reuse of the connection of the final hop
+
+	RequestCompression *BodyCompression       `json:"request_compression,omitempty"` // Sizes of the request body when it was compressed
+	Signature          *SignatureVerification `json:"signature,omitempty"`           // Verification of the response signature, with Request.VerifyKey
 }
 
 // HTTPTimings represents detailed timing information for an HTTP request
 type HTTPTimings struct {
-	DNSLookup     time.Duration `json:"dns_lookup"`      // DNS lookup time
+	DNSLookup     time.Duration `json:"dns_lookup"`     // DNS lookup time
 	TCPConnection time.Duration `json:"tcp_connection"` // TCP connection establishment time
 	TLSHandshake  time.Duration `json:"tls_handshake"`  // TLS handshake time (for HTTPS)
+	QUICHandshake time.Duration `json:"quic_handshake"` // QUIC handshake time, replaces TCP and TLS for HTTP/3
+	ProxyConnect  time.Duration `json:"proxy_connect"`  // Time to open the tunnel through the proxy (CONNECT or SOCKS), TCP Connection is then the connection to the proxy
+	RequestWrite  time.Duration `json:"request_write"`  // Time to send the request body, from the end of the headers to the end of the request
 	ServerTime    time.Duration `json:"server_time"`    // Time between sending request and receiving first byte of response
 	Transfer      time.Duration `json:"transfer"`       // Time to download the response body
 	Total         time.Duration `json:"total"`          // Total request time
@@ -102,33 +114,100 @@ func ParseQuery(queryInput string) (url.Values, error) {
 	return queryValues, nil
 }
 
-// ParseBody prepares the request body and content type based on input and body type
+// ParseBody prepares the request body and content type based on input and body type.
+// An @file body is streamed from the file, @- streams it from the standard input.
+// A form body is either already URL-encoded or a JSON object encoded field by field.
 func ParseBody(bodyInput, bodyType string) (io.Reader, string, error) {
 	if bodyInput == "" || bodyType == "none" {
 		return nil, "", nil
 	}
 
-	// Read from file if input starts with @
+	contentType, err := bodyContentType(bodyType)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if bodyType == "form" {
+		return parseFormBody(bodyInput, contentType)
+	}
+
+	switch {
+	case bodyInput == "@-":
+		if bodyType != "json" {
+			return io.NopCloser(os.Stdin), contentType, nil
+		}
+		// The standard input cannot be read twice, a JSON body is buffered to be validated
+		bodyContent, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read body from stdin: %w", err)
+		}
+		if err := validateJSON(bytes.NewReader(bodyContent)); err != nil {
+			return nil, "", fmt.Errorf("invalid JSON body: %w", err)
+		}
+		return bytes.NewBuffer(bodyContent), contentType, nil
+	case strings.HasPrefix(bodyInput, "@"):
+		file, err := os.Open(bodyInput[1:])
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read body file: %w", err)
+		}
+		if bodyType == "json" {
+			// Validate JSON without loading the file, then rewind it
+			err := validateJSON(file)
+			if err != nil {
+				file.Close()
+				return nil, "", fmt.Errorf("invalid JSON body: %w", err)
+			}
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				file.Close()
+				return nil, "", fmt.Errorf("failed to read body file: %w", err)
+			}
+		}
+		return file, contentType, nil
+	}
+
+	bodyContent := []byte(bodyInput)
+	if bodyType == "json" {
+		// Validate JSON
+		var js interface{}
+		if err := json.Unmarshal(bodyContent, &js); err != nil {
+			return nil, "", fmt.Errorf("invalid JSON body: %w", err)
+		}
+	}
+
+	return bytes.NewBuffer(bodyContent), contentType, nil
+}
+
+// parseFormBody reads a form body from text, an @file or the standard input and encodes it.
+// Form bodies are small, they are read in memory to be checked.
+func parseFormBody(bodyInput, contentType string) (io.Reader, string, error) {
 	var bodyContent []byte
 	var err error
-	if strings.HasPrefix(bodyInput, "@") {
-		bodyContent, err = os.ReadFile(bodyInput[1:])
-		if err != nil {
+	switch {
+	case bodyInput == "@-":
+		if bodyContent, err = io.ReadAll(os.Stdin); err != nil {
+			return nil, "", fmt.Errorf("failed to read body from stdin: %w", err)
+		}
+	case strings.HasPrefix(bodyInput, "@"):
+		if bodyContent, err = os.ReadFile(bodyInput[1:]); err != nil {
 			return nil, "", fmt.Errorf("failed to read body file: %w", err)
 		}
-	} else {
+	default:
 		bodyContent = []byte(bodyInput)
 	}
 
+	encoded, err := encodeFormBody(bodyContent)
+	if err != nil {
+		return nil, "", err
+	}
+	return strings.NewReader(encoded), contentType, nil
+}
+
+// bodyContentType returns the content type of a body type
+func bodyContentType(bodyType string) (string, error) {
 	contentType := ""
 	switch bodyType {
 	case "json":
 		contentType = "application/json"
-		// Validate JSON
-		var js interface{}
-		if err := json.Unmarshal(bodyContent, &js); err != nil {
-			return nil, "", fmt.Errorf("invalid JSON body: %w", err)
-		}
 	case "text":
 		contentType = "text/plain"
 	case "form":
@@ -140,33 +219,363 @@ func ParseBody(bodyInput, bodyType string) (io.Reader, string, error) {
 	case "xml":
 		contentType = "application/xml"
 	default:
-		return nil, "", fmt.Errorf("unsupported body type: %s", bodyType)
+		return "", fmt.Errorf("unsupported body type: %s", bodyType)
 	}
+	return contentType, nil
+}
 
-	return bytes.NewBuffer(bodyContent), contentType, nil
+// validateJSON checks that r holds a single JSON value, reading it as a stream of tokens
+func validateJSON(r io.Reader) error {
+	decoder := json.NewDecoder(r)
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("unexpected data after the top-level value")
+	}
+	return nil
+}
+
+// Request describes an HTTP request to be sent by a Client.
+// Headers and Query accept JSON text or an @file reference, Body accepts
+// raw text or an @file reference, exactly like the CLI flags.
+type Request struct {
+	Method   string
+	URL      string
+	Headers  string
+	Query    string
+	Body     string
+	BodyType string
+	Fields   []string // Parts of a multipart body, see ParseMultipartField
+	Form     []string // Fields of a form body as key=value, used in place of Body
+	Cookies  []string // Cookies sent with this request only, as name=value
+	Auth     *Auth    // Credentials sent in the Authorization header
+
+	// SigV4 signs the request with AWS Signature Version 4 once it is built,
+	// each attempt is signed again with the time it is sent
+	SigV4 *AWSSigV4
+
+	// Signature signs the request with HTTP Message Signatures once it is built,
+	// after SigV4, and VerifyKey verifies the signature of the response. A response
+	// signature created more than VerifyMaxAge ago is rejected, zero accepts any age.
+	Signature    *MessageSignature
+	VerifyKey    *SignatureKey
+	VerifyMaxAge time.Duration
+
+	// CompressBody is the content coding applied to the body: gzip, zstd or deflate.
+	// The body is sent as is when it is empty.
+	CompressBody string
+
+	// Output receives the response body as it is read instead of Response.Body,
+	// so large downloads are never held in memory
+	Output io.Writer
+
+	// boundary is the multipart boundary of the first build, a body rebuilt to be sent
+	// again or to be signed keeps it so it matches the Content-Type header
+	boundary string
 }
 
-// SendRequest sends an HTTP request and returns a formatted response
-func SendRequest(method, targetURL, headersInput, queryInput, bodyInput, bodyType string, showProgress bool) (*Response, error) {
-	// Initialize progress display
-	progress := ui.NewProgressDisplay(showProgress)
+// Do sends the request using the client's base context
+func (c *Client) Do(req *Request) (*Response, error) {
+	return c.DoContext(c.ctx, req)
+}
+
+// DoContext sends the request and returns a formatted response.
+// The request is cancelled when ctx is done.
+func (c *Client) DoContext(ctx context.Context, req *Request) (*Response, error) {
+	progress := c.progress
 	progress.Start()
 	defer progress.Complete()
 
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	var attempts []Attempt
+	for number := 1; ; number++ {
+		// A body read from the standard input cannot be sent again
+		retryable := c.retry.canRetry(req.Method) && number <= c.retry.Retries && !req.readsStdin()
+		resp, err := c.send(ctx, req, progress, retryable)
+
+		attempt := Attempt{Number: number}
+		retry := retryable && ctx.Err() == nil
+		if err != nil {
+			attempt.Error = err.Error()
+			retry = retry && isRetryableError(err)
+		} else {
+			attempt.StatusCode = resp.StatusCode
+			attempt.Timings = resp.Timings
+			retry = retry && retryableStatus[resp.StatusCode]
+		}
+
+		if !retry {
+			attempts = append(attempts, attempt)
+			if err != nil {
+				if number > 1 {
+					return nil, fmt.Errorf("%w (after %d attempts)", err, number)
+				}
+				return nil, err
+			}
+			resp.Attempts = attempts
+			return resp, writeOutput(req, resp)
+		}
+
+		attempt.Delay = c.retry.backoff(number, resp)
+		attempts = append(attempts, attempt)
+		if c.retry.OnRetry != nil {
+			c.retry.OnRetry(attempt, attempt.Delay)
+		}
+		if sleepContext(ctx, attempt.Delay) != nil {
+			// The context ended while waiting, report the last attempt
+			if err != nil {
+				return nil, fmt.Errorf("%w (after %d attempts)", err, number)
+			}
+			resp.Attempts = attempts
+			return resp, writeOutput(req, resp)
+		}
+	}
+}
+
+// writeOutput moves a body kept in memory for a possible retry to the request output
+func writeOutput(req *Request, resp *Response) error {
+	if req.Output == nil || resp.Body == "" {
+		return nil
+	}
+	if _, err := io.WriteString(req.Output, resp.Body); err != nil {
+		return fmt.Errorf("failed to write response body: %w", err)
+	}
+	resp.Body = ""
+	return nil
+}
+
+// send performs a single attempt of the request, retryable tells whether a failed attempt may be sent again
+func (c *Client) send(ctx context.Context, req *Request, progress ProgressObserver, retryable bool) (*Response, error) {
+	httpReq, err := c.buildRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize timing variables and add the trace to the request context
+	timings := &HTTPTimings{}
+	trace := newRequestTrace(timings, progress)
+	httpReq = httpReq.WithContext(withRequestTrace(httpReq.Context(), trace))
+	compressed, _ := httpReq.Body.(*compressedBody)
+	if httpReq.Body != nil && httpReq.Body != http.NoBody {
+		total := httpReq.ContentLength
+		if total == 0 {
+			total = -1
+		}
+		httpReq.Body = newUploadBody(httpReq.Body, newTransferCounter(progress, "upload", total))
+		// A body sent again after a redirect or an auth challenge is counted from the start
+		if getBody := httpReq.GetBody; getBody != nil {
+			httpReq.GetBody = func() (io.ReadCloser, error) {
+				body, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return newUploadBody(body, newTransferCounter(progress, "upload", total)), nil
+			}
+		}
+	}
+	decode := c.negotiateEncoding(httpReq)
+
+	// Send request and measure time
+	startTime := time.Now()
+	resp, err := c.httpClient.Do(httpReq)
+	timings.Total = time.Since(startTime)
+
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", c.timeoutError(ctx, trace, err))
+	}
+	defer resp.Body.Close()
+
+	if err := c.checkProtocol(resp); err != nil {
+		return nil, err
+	}
+
+	// A response that will be retried is kept in memory, only the final body goes to the output
+	var body bytes.Buffer
+	var output io.Writer = &body
+	streamed := req.Output != nil && !(retryable && retryableStatus[resp.StatusCode])
+	if streamed {
+		output = req.Output
+	}
+
+	// Read response body, the progress follows the bytes received before decoding
+	trace.setPhase(PhaseTransfer)
+	downloadStart := time.Now()
+	counter := newTransferCounter(progress, "download", resp.ContentLength)
+	var reader io.Reader = io.TeeReader(resp.Body, counter)
+	// Content-Digest covers the body as received, before its content codings are removed
+	var digests *bodyDigests
+	if req.VerifyKey != nil {
+		digests = newBodyDigests()
+		reader = io.TeeReader(reader, digests)
+	}
+	encoding := ""
+	if decode {
+		decoded, codings, err := decodeBody(reader, resp.Header.Get("Content-Encoding"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", c.timeoutError(ctx, trace, err))
+		}
+		defer decoded.Close()
+		reader, encoding = decoded, codings
+	}
+	bodySize, err := io.Copy(output, reader)
+	counter.done()
+	timings.Transfer = time.Since(downloadStart)
+	progress.Update("response_complete", "completed", timings.Transfer)
+
+	if err != nil {
+		err = fmt.Errorf("failed to read response body: %w", c.timeoutError(ctx, trace, err))
+		if streamed && bodySize > 0 {
+			// Part of the body is already in the output, it cannot be sent again
+			err = &permanentError{err}
+		}
+		return nil, err
+	}
+
+	// Format response
+	formattedResp := &Response{
+		StatusCode:    resp.StatusCode,
+		Protocol:      resp.Proto,
+		Headers:       FormatHeaders(resp.Header),
+		Body:          body.String(),
+		BodySize:      bodySize,
+		ContentLength: resp.ContentLength,
+		WireSize:      counter.count,
+		Encoding:      encoding,
+		Time:          time.Since(startTime),
+		Timings:       timings,
+		TLS:           newTLSInfo(resp.TLS),
+		URL:           resp.Request.URL.String(),
+		Redirects:     trace.redirects,
+		UnixSocket:    c.unixSocket,
+		Connection:    trace.connectionInfo(),
+	}
+	if compressed != nil {
+		formattedResp.RequestCompression = compressed.stats()
+	}
+	if req.VerifyKey != nil {
+		formattedResp.Signature = verifyResponseSignature(resp, req.VerifyKey, req.VerifyMaxAge, digests)
+	}
+
+	return formattedResp, nil
+}
+
+// parseBody prepares the request body and compresses it when CompressBody is set
+func (req *Request) parseBody() (io.Reader, string, error) {
+	body, contentType, err := req.parseContent()
+	if err != nil || body == nil || req.CompressBody == "" {
+		return body, contentType, err
+	}
+	compressed, err := compressBody(body, req.CompressBody)
+	if err != nil {
+		if closer, ok := body.(io.Closer); ok {
+			closer.Close()
+		}
+		return nil, "", err
+	}
+	return compressed, contentType, nil
+}
+
+// parseContent prepares the uncompressed request body, a multipart body is built
+// from Fields and a form body from Form when it is set
+func (req *Request) parseContent() (io.Reader, string, error) {
+	if len(req.Fields) > 0 && len(req.Form) > 0 {
+		return nil, "", fmt.Errorf("a body is either multipart or a form, multipart fields and form fields cannot be combined")
+	}
+	if req.BodyType == "form" && len(req.Form) > 0 {
+		if req.Body != "" {
+			return nil, "", fmt.Errorf("a form body is built either from its fields or from a body, not both")
+		}
+		encoded, err := EncodeForm(req.Form)
+		if err != nil {
+			return nil, "", err
+		}
+		return strings.NewReader(encoded), "application/x-www-form-urlencoded", nil
+	}
+	if req.BodyType != "multipart" {
+		return ParseBody(req.Body, req.BodyType)
+	}
+	if req.Body != "" {
+		return nil, "", fmt.Errorf("a multipart body is built from its fields, it does not take a body")
+	}
+	body, contentType, err := parseMultipart(req.Fields, req.boundary)
+	if multipart, ok := body.(*multipartBody); ok {
+		req.boundary = multipart.boundary
+	}
+	return body, contentType, err
+}
+
+// readsStdin reports whether the request body is read from the standard input
+func (req *Request) readsStdin() bool {
+	if req.BodyType != "multipart" {
+		return req.Body == "@-"
+	}
+	for _, spec := range req.Fields {
+		if field, err := ParseMultipartField(spec); err == nil && field.File == "-" {
+			return true
+		}
+	}
+	return false
+}
+
+// buildRequest parses the request inputs and builds the underlying *http.Request
+func (c *Client) buildRequest(ctx context.Context, req *Request) (*http.Request, error) {
 	// Parse headers
-	headers, err := ParseHeaders(headersInput)
+	headers, err := ParseHeaders(req.Headers)
 	if err != nil {
 		return nil, fmt.Errorf("header parsing error: %w", err)
 	}
+	cookies := make([]*http.Cookie, 0, len(req.Cookies))
+	for _, spec := range req.Cookies {
+		cookie, err := ParseCookie(spec)
+		if err != nil {
+			return nil, err
+		}
+		cookies = append(cookies, cookie)
+	}
+	if req.Auth != nil {
+		if err := req.Auth.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if req.SigV4 != nil {
+		if err := req.SigV4.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if req.Signature != nil && req.Signature.Key == nil {
+		return nil, fmt.Errorf("message signature needs a key")
+	}
 
 	// Parse query parameters
-	queryValues, err := ParseQuery(queryInput)
+	queryValues, err := ParseQuery(req.Query)
 	if err != nil {
 		return nil, fmt.Errorf("query parsing error: %w", err)
 	}
 
 	// Parse URL and add query parameters
-	parsedURL, err := url.Parse(targetURL)
+	parsedURL, err := url.Parse(req.URL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid URL: %w", err)
 	}
@@ -183,129 +592,106 @@ func SendRequest(method, targetURL, headersInput, queryInput, bodyInput, bodyTyp
 	}
 
 	// Parse body
-	body, contentType, err := ParseBody(bodyInput, bodyType)
+	body, contentType, err := req.parseBody()
 	if err != nil {
 		return nil, fmt.Errorf("body parsing error: %w", err)
 	}
 
+	// Credentials that answer a 401 response travel with the request, see authTransport
+	if req.Auth != nil && req.Auth.answersUnauthorized() {
+		ctx = withAuthTarget(ctx, req.Auth, parsedURL.Host)
+	}
+
 	// Create request
-	req, err := http.NewRequest(method, parsedURL.String(), body)
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, parsedURL.String(), body)
 	if err != nil {
+		if closer, ok := body.(io.Closer); ok {
+			closer.Close()
+		}
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
+	// File and multipart bodies are streamed with their size and rebuilt when a redirect sends
+	// them again, the standard input has no known size and is sent chunked
+	rebuild := false
+	switch body := body.(type) {
+	case *os.File:
+		if info, err := body.Stat(); err == nil && info.Mode().IsRegular() {
+			httpReq.ContentLength = info.Size()
+		}
+		rebuild = true
+	case *multipartBody:
+		if body.size >= 0 {
+			httpReq.ContentLength = body.size
+			rebuild = true
+		}
+	case *compressedBody:
+		// A body compressed as it is sent has no known size and is sent chunked
+		if body.data != nil {
+			httpReq.ContentLength = int64(len(body.data))
+			httpReq.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(body.data)), nil
+			}
+		}
+		rebuild = body.reopenable
+	}
+	if rebuild {
+		httpReq.GetBody = func() (io.ReadCloser, error) {
+			body, _, err := req.parseBody()
+			if err != nil {
+				return nil, err
+			}
+			return body.(io.ReadCloser), nil
+		}
+	}
+
 	// Add headers
 	for key, values := range headers {
 		for _, value := range values {
-			req.Header.Add(key, value)
+			httpReq.Header.Add(key, value)
 		}
 	}
 
-	// Set content type if provided
-	if contentType != "" {
-		req.Header.Set("Content-Type", contentType)
-	}
-
-	// Create a context with a cancel function
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Create a transport with a TLS config that skips verification
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	for _, cookie := range cookies {
+		httpReq.AddCookie(cookie)
 	}
-
-	// Create a client with the transport
-	client := &http.Client{Transport: transport}
-
-	// Initialize timing variables
-	timings := &HTTPTimings{}
-	var dnsStart, connectStart, tlsStart, firstByteStart time.Time
-
-	// Create a trace context
-	trace := &httptrace.ClientTrace{
-		DNSStart: func(info httptrace.DNSStartInfo) {
-			dnsStart = time.Now()
-			progress.Update("dns_start", "started", 0)
-		},
-		DNSDone: func(info httptrace.DNSDoneInfo) {
-			if !dnsStart.IsZero() {
-				timings.DNSLookup = time.Since(dnsStart)
-				progress.Update("dns_complete", "completed", timings.DNSLookup)
-			}
-		},
-		ConnectStart: func(network, addr string) {
-			connectStart = time.Now()
-			progress.Update("connect_start", "started", 0)
-		},
-		ConnectDone: func(network, addr string, err error) {
-			if !connectStart.IsZero() {
-				timings.TCPConnection = time.Since(connectStart)
-				progress.Update("connect_complete", "completed", timings.TCPConnection)
-			}
-		},
-		TLSHandshakeStart: func() {
-			tlsStart = time.Now()
-			progress.Update("tls_start", "started", 0)
-		},
-		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
-			if !tlsStart.IsZero() {
-				timings.TLSHandshake = time.Since(tlsStart)
-				progress.Update("tls_complete", "completed", timings.TLSHandshake)
+	if req.Auth != nil {
+		if err := req.Auth.authorize(ctx, httpReq); err != nil {
+			if httpReq.Body != nil {
+				httpReq.Body.Close()
 			}
-		},
-		WroteRequest: func(info httptrace.WroteRequestInfo) {
-			progress.Update("request_sent", "completed", 0)
-		},
-		GotFirstResponseByte: func() {
-			if firstByteStart.IsZero() {
-				firstByteStart = time.Now()
-			}
-			if !connectStart.IsZero() {
-				timings.ServerTime = firstByteStart.Sub(connectStart)
-				if timings.TLSHandshake > 0 {
-					// Subtract TLS handshake time to get actual server processing time
-					timings.ServerTime -= timings.TLSHandshake
-				}
-				progress.Update("response_first_byte", "completed", timings.ServerTime)
-			}
-		},
+			return nil, err
+		}
 	}
 
-	// Add the trace to the request context
-	ctx = httptrace.WithClientTrace(ctx, trace)
-
-	// Send request and measure time
-	startTime := time.Now()
-	resp, err := client.Do(req.WithContext(ctx))
-	timings.Total = time.Since(startTime)
-
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+	// Set content type if provided
+	if contentType != "" {
+		httpReq.Header.Set("Content-Type", contentType)
 	}
-	defer resp.Body.Close()
-
-	// Read response body
-	downloadStart := time.Now()
-	respBody, err := io.ReadAll(resp.Body)
-	timings.Transfer = time.Since(downloadStart)
-	progress.Update("response_complete", "completed", timings.Transfer)
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+	if compressed, ok := body.(*compressedBody); ok {
+		httpReq.Header.Set("Content-Encoding", compressed.encoding)
 	}
 
-	// Format response
-	formattedResp := &Response{
-		StatusCode:    resp.StatusCode,
-		Headers:       FormatHeaders(resp.Header),
-		Body:          string(respBody),
-		ContentLength: resp.ContentLength,
-		Time:          time.Since(startTime),
-		Timings:       timings,
+	// The signatures cover the request as it is sent, they come last
+	now := time.Now()
+	if req.SigV4 != nil {
+		if err := req.SigV4.sign(httpReq, now); err != nil {
+			if httpReq.Body != nil {
+				httpReq.Body.Close()
+			}
+			return nil, err
+		}
+	}
+	if req.Signature != nil {
+		if err := req.Signature.sign(httpReq, now); err != nil {
+			if httpReq.Body != nil {
+				httpReq.Body.Close()
+			}
+			return nil, err
+		}
 	}
 
-	return formattedResp, nil
+	return httpReq, nil
 }
 
 // Helper functions to parse JSON from string or file
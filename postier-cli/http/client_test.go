@@ -0,0 +1,199 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// echoServer answers with the method, query, headers and body it received
+func echoServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Query", r.URL.RawQuery)
+		w.Header().Set("X-Token", r.Header.Get("X-Token"))
+		w.Header().Set("X-Content-Type", r.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClientDo(t *testing.T) {
+	server := echoServer(t)
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Do(&Request{
+		Method:   "PUT",
+		URL:      server.URL + "/users?page=2",
+		Headers:  `{"X-Token": "abc"}`,
+		Query:    `{"sort": "name"}`,
+		Body:     `{"name": "john"}`,
+		BodyType: "json",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusCreated || resp.Body != `{"name": "john"}` {
+		t.Errorf("got %d %q, want 201 with the body sent", resp.StatusCode, resp.Body)
+	}
+	want := map[string]string{
+		"X-Method":       "PUT",
+		"X-Query":        "page=2&sort=name",
+		"X-Token":        "abc",
+		"X-Content-Type": "application/json",
+	}
+	for key, value := range want {
+		if resp.Headers[key] != value {
+			t.Errorf("%s: got %q, want %q", key, resp.Headers[key], value)
+		}
+	}
+	if resp.Protocol != "HTTP/1.1" || resp.URL != server.URL+"/users?page=2&sort=name" {
+		t.Errorf("got %s %s", resp.Protocol, resp.URL)
+	}
+	if resp.Timings == nil || resp.Timings.Total <= 0 || resp.Time <= 0 {
+		t.Errorf("timings not recorded: %+v", resp.Timings)
+	}
+}
+
+func TestClientReusesConnections(t *testing.T) {
+	var mu sync.Mutex
+	opened := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			opened++
+			mu.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := client.Do(&Request{Method: "GET", URL: server.URL}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if opened != 1 {
+		t.Errorf("got %d connections for 3 requests, want 1", opened)
+	}
+}
+
+func TestClientHeadersFromFile(t *testing.T) {
+	server := echoServer(t)
+	file := filepath.Join(t.TempDir(), "headers.json")
+	if err := os.WriteFile(file, []byte(`{"X-Token": "from file"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(&Request{Method: "GET", URL: server.URL, Headers: "@" + file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Headers["X-Token"] != "from file" {
+		t.Errorf("got X-Token %q, want the header of the file", resp.Headers["X-Token"])
+	}
+}
+
+func TestClientInvalidInput(t *testing.T) {
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name    string
+		req     *Request
+		wantErr string
+	}{
+		{"headers", &Request{Method: "GET", URL: "http://localhost", Headers: "{"}, "header parsing error"},
+		{"query", &Request{Method: "GET", URL: "http://localhost", Query: "[]"}, "query parsing error"},
+		{"json body", &Request{Method: "POST", URL: "http://localhost", Body: `{"a":`, BodyType: "json"}, "invalid JSON body"},
+		{"body type", &Request{Method: "POST", URL: "http://localhost", Body: "a", BodyType: "yaml"}, "unsupported body type"},
+		{"url", &Request{Method: "GET", URL: "http://[::1"}, "invalid URL"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := client.Do(test.req)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("got %v, want an error with %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	client, err := NewClient(WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(&Request{Method: "GET", URL: server.URL}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the deadline of the client context", err)
+	}
+}
+
+// recordingProgress records the phases reported to a progress observer
+type recordingProgress struct {
+	mu                 sync.Mutex
+	started, completed bool
+	phases             []string
+}
+
+func (p *recordingProgress) Start() { p.started = true }
+
+func (p *recordingProgress) Update(phase, _ string, _ time.Duration) {
+	p.mu.Lock()
+	p.phases = append(p.phases, phase)
+	p.mu.Unlock()
+}
+
+func (p *recordingProgress) Complete() { p.completed = true }
+
+func TestClientProgress(t *testing.T) {
+	server := echoServer(t)
+	progress := &recordingProgress{}
+	client, err := NewClient(WithProgress(progress))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(&Request{Method: "GET", URL: server.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if !progress.started || !progress.completed {
+		t.Errorf("got started %t completed %t, want both", progress.started, progress.completed)
+	}
+	if len(progress.phases) == 0 || progress.phases[len(progress.phases)-1] != "response_complete" {
+		t.Errorf("got phases %v, want response_complete last", progress.phases)
+	}
+}
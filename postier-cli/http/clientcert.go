@@ -0,0 +1,154 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// ErrPassphraseRequired is returned when the client key is encrypted and no passphrase was given
+var ErrPassphraseRequired = errors.New("client key is encrypted, a passphrase is required")
+
+// ClientCert describes the certificate presented to servers that require mutual TLS
+type ClientCert struct {
+	CertFile   string // PEM certificate chain, or PKCS#12 bundle when Type is "p12"
+	KeyFile    string // PEM private key, defaults to CertFile when empty
+	Type       string // "pem" (default) or "p12"
+	Passphrase string // Passphrase of an encrypted key or PKCS#12 bundle
+}
+
+// WithClientCert presents a client certificate during the TLS handshake
+func WithClientCert(cert ClientCert) Option {
+	return func(c *Client) {
+		c.clientCert = cert
+	}
+}
+
+// loadClientCert loads the client certificate and its private key
+func loadClientCert(cert ClientCert) (tls.Certificate, error) {
+	switch strings.ToLower(cert.Type) {
+	case "", "pem":
+		return loadPEMClientCert(cert)
+	case "p12", "pkcs12", "pfx":
+		return loadP12ClientCert(cert)
+	default:
+		return tls.Certificate{}, fmt.Errorf("unsupported certificate type: %s", cert.Type)
+	}
+}
+
+// loadPEMClientCert loads a PEM certificate chain and a PEM private key, optionally encrypted
+func loadPEMClientCert(cert ClientCert) (tls.Certificate, error) {
+	certData, err := os.ReadFile(cert.CertFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to read client certificate %s: %w", cert.CertFile, err)
+	}
+
+	keyFile := cert.KeyFile
+	if keyFile == "" {
+		keyFile = cert.CertFile
+	}
+	keyData, err := os.ReadFile(keyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to read client key %s: %w", keyFile, err)
+	}
+
+	// Keep only the certificates, the key file may be the same file
+	var certPEM []byte
+	var keyBlock *pem.Block
+	for rest := certData; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			certPEM = append(certPEM, pem.EncodeToMemory(block)...)
+		}
+	}
+	for rest := keyData; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			keyBlock = block
+			break
+		}
+	}
+	if len(certPEM) == 0 {
+		return tls.Certificate{}, fmt.Errorf("no PEM certificate found in %s", cert.CertFile)
+	}
+	if keyBlock == nil {
+		return tls.Certificate{}, fmt.Errorf("no PEM private key found in %s", keyFile)
+	}
+
+	keyBlock, err = decryptKeyBlock(keyBlock, cert.Passphrase)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	pair, err := tls.X509KeyPair(certPEM, pem.EncodeToMemory(keyBlock))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("invalid client certificate: %w", err)
+	}
+	return pair, nil
+}
+
+// decryptKeyBlock returns the unencrypted form of a PKCS#8 encrypted key. Keys with the
+// legacy OpenSSL encryption (Proc-Type header) are rejected, it cannot be authenticated.
+func decryptKeyBlock(block *pem.Block, passphrase string) (*pem.Block, error) {
+	switch {
+	case block.Type == "ENCRYPTED PRIVATE KEY":
+		if passphrase == "" {
+			return nil, ErrPassphraseRequired
+		}
+		key, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte(passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt client key: %w", err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode client key: %w", err)
+		}
+		return &pem.Block{Type: "PRIVATE KEY", Bytes: der}, nil
+	case strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED"):
+		return nil, fmt.Errorf("client key uses the legacy PEM encryption, which is not supported: " +
+			"convert it to an encrypted PKCS#8 key with openssl pkcs8 -topk8")
+	default:
+		return block, nil
+	}
+}
+
+// loadP12ClientCert loads a certificate, its chain and private key from a PKCS#12 bundle
+func loadP12ClientCert(cert ClientCert) (tls.Certificate, error) {
+	data, err := os.ReadFile(cert.CertFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to read PKCS#12 bundle %s: %w", cert.CertFile, err)
+	}
+
+	key, leaf, chain, err := pkcs12.DecodeChain(data, cert.Passphrase)
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) && cert.Passphrase == "" {
+		return tls.Certificate{}, ErrPassphraseRequired
+	}
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to decode PKCS#12 bundle %s: %w", cert.CertFile, err)
+	}
+
+	pair := tls.Certificate{
+		Certificate: [][]byte{leaf.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	for _, ca := range chain {
+		pair.Certificate = append(pair.Certificate, ca.Raw)
+	}
+	return pair, nil
+}
@@ -0,0 +1,140 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// mutualTLSServer requires a client certificate and answers with its common name
+func mutualTLSServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClientCert(t *testing.T) {
+	server := mutualTLSServer(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "postier client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	plainKey, _ := x509.MarshalPKCS8PrivateKey(key)
+	encryptedKey, err := pkcs8.MarshalPrivateKey(key, []byte("secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := pkcs12.Modern.Encode(key, leaf, nil, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	write := func(name string, blocks ...*pem.Block) string {
+		var data []byte
+		for _, block := range blocks {
+			data = append(data, pem.EncodeToMemory(block)...)
+		}
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	certBlock := &pem.Block{Type: "CERTIFICATE", Bytes: der}
+	combined := write("combined.pem", certBlock, &pem.Block{Type: "PRIVATE KEY", Bytes: plainKey})
+	certFile := write("client.pem", certBlock)
+	encryptedFile := write("encrypted.key", &pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encryptedKey})
+	legacyFile := write("legacy.key", &pem.Block{
+		Type:    "EC PRIVATE KEY",
+		Headers: map[string]string{"Proc-Type": "4,ENCRYPTED", "DEK-Info": "AES-128-CBC,00000000000000000000000000000000"},
+		Bytes:   plainKey,
+	})
+	p12File := filepath.Join(dir, "client.p12")
+	if err := os.WriteFile(p12File, bundle, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name    string
+		cert    ClientCert
+		wantErr string // Empty when the server accepts the certificate
+	}{
+		{"pem with key", ClientCert{CertFile: combined}, ""},
+		{"encrypted pkcs8", ClientCert{CertFile: certFile, KeyFile: encryptedFile, Passphrase: "secret"}, ""},
+		{"encrypted pkcs8 without passphrase", ClientCert{CertFile: certFile, KeyFile: encryptedFile}, ErrPassphraseRequired.Error()},
+		{"encrypted pkcs8 wrong passphrase", ClientCert{CertFile: certFile, KeyFile: encryptedFile, Passphrase: "wrong"}, "failed to decrypt client key"},
+		{"legacy encryption", ClientCert{CertFile: certFile, KeyFile: legacyFile, Passphrase: "secret"}, "legacy PEM encryption"},
+		{"pkcs12", ClientCert{CertFile: p12File, Type: "p12", Passphrase: "secret"}, ""},
+		{"pkcs12 without passphrase", ClientCert{CertFile: p12File, Type: "p12"}, ErrPassphraseRequired.Error()},
+		{"missing key", ClientCert{CertFile: certFile}, "no PEM private key"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := NewClient(WithInsecure(true), WithClientCert(test.cert))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got %v, want an error with %q", err, test.wantErr)
+				}
+				if test.wantErr == ErrPassphraseRequired.Error() && !errors.Is(err, ErrPassphraseRequired) {
+					t.Errorf("got %v, want ErrPassphraseRequired", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(&Request{Method: "GET", URL: server.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Body != "postier client" {
+				t.Errorf("server saw certificate %q, want postier client", resp.Body)
+			}
+		})
+	}
+}
+
+func TestClientCertRequired(t *testing.T) {
+	server := mutualTLSServer(t)
+	client, err := NewClient(WithInsecure(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(&Request{Method: "GET", URL: server.URL}); err == nil {
+		t.Error("request without a client certificate accepted")
+	}
+}
@@ -0,0 +1,256 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Accept-Encoding values sent when the client negotiates the response encoding
+const (
+	defaultAcceptEncoding    = "gzip"
+	compressedAcceptEncoding = "gzip, deflate, br, zstd"
+)
+
+// WithCompressed asks for a compressed response with every encoding the client
+// can decode (gzip, deflate, br and zstd) instead of gzip only
+func WithCompressed(enabled bool) Option {
+	return func(c *Client) {
+		c.compressed = enabled
+	}
+}
+
+// negotiateEncoding sets the Accept-Encoding header of the request, like the standard
+// transport does for gzip, and reports whether the response body should be decoded.
+// Headers set by the user are kept and their response is only decoded with --compressed.
+func (c *Client) negotiateEncoding(req *http.Request) bool {
+	if req.Header.Get("Accept-Encoding") != "" || req.Header.Get("Range") != "" || req.Method == http.MethodHead {
+		return c.compressed
+	}
+	if c.compressed {
+		req.Header.Set("Accept-Encoding", compressedAcceptEncoding)
+	} else {
+		req.Header.Set("Accept-Encoding", defaultAcceptEncoding)
+	}
+	return true
+}
+
+// decodedBody reads a response body through the decoders of its content codings
+type decodedBody struct {
+	io.Reader
+	closers []func()
+}
+
+// Close releases the decoders, the response body itself is closed by its owner
+func (b *decodedBody) Close() {
+	for _, closeDecoder := range b.closers {
+		closeDecoder()
+	}
+}
+
+// decodeBody wraps body to remove the content codings listed in contentEncoding,
+// applied by the server in the listed order. The body is returned as is, with an
+// empty coding list, when it is empty or uses a coding that cannot be decoded.
+func decodeBody(body io.Reader, contentEncoding string) (*decodedBody, string, error) {
+	var codings []string
+	for _, coding := range strings.Split(contentEncoding, ",") {
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		switch coding {
+		case "", "identity":
+		case "gzip", "x-gzip", "deflate", "br", "zstd":
+			codings = append(codings, coding)
+		default:
+			return &decodedBody{Reader: body}, "", nil
+		}
+	}
+	if len(codings) == 0 {
+		return &decodedBody{Reader: body}, "", nil
+	}
+
+	// A response without a body, e.g. 204 or 304, may still announce its encoding
+	buffered := bufio.NewReader(body)
+	if _, err := buffered.Peek(1); err == io.EOF {
+		return &decodedBody{Reader: buffered}, "", nil
+	}
+
+	decoded := &decodedBody{Reader: buffered}
+	for i := len(codings) - 1; i >= 0; i-- {
+		if err := decoded.push(codings[i]); err != nil {
+			decoded.Close()
+			return nil, "", fmt.Errorf("failed to decode %s body: %w", codings[i], err)
+		}
+	}
+	return decoded, strings.Join(codings, ", "), nil
+}
+
+// push adds the decoder of one content coding on top of the current reader
+func (b *decodedBody) push(coding string) error {
+	switch coding {
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(b.Reader)
+		if err != nil {
+			return err
+		}
+		b.Reader, b.closers = reader, append(b.closers, func() { reader.Close() })
+	case "deflate":
+		// deflate is meant to be zlib wrapped, but some servers send raw deflate data
+		buffered := bufio.NewReader(b.Reader)
+		if header, err := buffered.Peek(2); err == nil && isZlibHeader(header) {
+			reader, err := zlib.NewReader(buffered)
+			if err != nil {
+				return err
+			}
+			b.Reader, b.closers = reader, append(b.closers, func() { reader.Close() })
+		} else {
+			reader := flate.NewReader(buffered)
+			b.Reader, b.closers = reader, append(b.closers, func() { reader.Close() })
+		}
+	case "br":
+		b.Reader = brotli.NewReader(b.Reader)
+	case "zstd":
+		reader, err := zstd.NewReader(b.Reader, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return err
+		}
+		b.Reader, b.closers = reader, append(b.closers, reader.Close)
+	}
+	return nil
+}
+
+// isZlibHeader reports whether the first two bytes of a stream are a zlib header
+func isZlibHeader(header []byte) bool {
+	return header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
+
+// BodyCompression reports the size of a request body before and after compression
+type BodyCompression struct {
+	Encoding       string `json:"encoding"`
+	OriginalSize   int64  `json:"original_size"`
+	CompressedSize int64  `json:"compressed_size"`
+}
+
+// compressedBody is a request body compressed with a content coding. A body held in
+// memory is compressed up front, a streamed one is compressed as it is sent.
+type compressedBody struct {
+	io.Reader
+	encoding   string
+	original   atomic.Int64
+	compressed atomic.Int64
+	data       []byte // Compressed body, nil when it is compressed as it is sent
+	reopenable bool   // The source of a streamed body can be read again
+	pipe       *io.PipeReader
+}
+
+// compressBody compresses body with encoding: gzip, zstd or deflate
+func compressBody(body io.Reader, encoding string) (*compressedBody, error) {
+	switch encoding {
+	case "gzip", "zstd", "deflate":
+	default:
+		return nil, fmt.Errorf("unsupported body compression: %s", encoding)
+	}
+	compressed := &compressedBody{encoding: encoding}
+
+	switch body := body.(type) {
+	case *bytes.Buffer, *bytes.Reader, *strings.Reader:
+		var buf bytes.Buffer
+		encoder, err := newBodyEncoder(&buf, encoding)
+		if err != nil {
+			return nil, err
+		}
+		n, err := io.Copy(encoder, body)
+		if err == nil {
+			err = encoder.Close()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress body: %w", err)
+		}
+		compressed.Reader, compressed.data = bytes.NewReader(buf.Bytes()), buf.Bytes()
+		compressed.original.Store(n)
+		return compressed, nil
+	case *os.File:
+		compressed.reopenable = true
+	case *multipartBody:
+		compressed.reopenable = body.size >= 0
+	}
+
+	// The body is compressed into a pipe while the transport reads the other end
+	pipeReader, pipeWriter := io.Pipe()
+	compressed.Reader, compressed.pipe = pipeReader, pipeReader
+	go func() {
+		encoder, err := newBodyEncoder(pipeWriter, encoding)
+		if err == nil {
+			_, err = io.Copy(encoder, &countingReader{Reader: body, count: &compressed.original})
+		}
+		if err == nil {
+			err = encoder.Close()
+		}
+		if closer, ok := body.(io.Closer); ok {
+			closer.Close()
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to compress body: %w", err)
+		}
+		pipeWriter.CloseWithError(err)
+	}()
+	return compressed, nil
+}
+
+func (b *compressedBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	b.compressed.Add(int64(n))
+	return n, err
+}
+
+// Close stops the compression of a streamed body, which then closes its source
+func (b *compressedBody) Close() error {
+	if b.pipe != nil {
+		return b.pipe.Close()
+	}
+	return nil
+}
+
+// stats returns the sizes of the body sent so far
+func (b *compressedBody) stats() *BodyCompression {
+	return &BodyCompression{
+		Encoding:       b.encoding,
+		OriginalSize:   b.original.Load(),
+		CompressedSize: b.compressed.Load(),
+	}
+}
+
+// newBodyEncoder returns a writer compressing to w with a content coding
+func newBodyEncoder(w io.Writer, encoding string) (io.WriteCloser, error) {
+	switch encoding {
+	case "gzip":
+		return gzip.NewWriter(w), nil
+	case "deflate":
+		// HTTP deflate is the zlib format
+		return zlib.NewWriter(w), nil
+	case "zstd":
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	}
+	return nil, fmt.Errorf("unsupported body compression: %s", encoding)
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	io.Reader
+	count *atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.count.Add(int64(n))
+	return n, err
+}
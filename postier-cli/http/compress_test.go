@@ -0,0 +1,268 @@
+package http
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// encodeWith compresses data with a content coding, raw-deflate being deflate without the zlib wrapper
+func encodeWith(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var encoder io.WriteCloser
+	var err error
+	switch coding {
+	case "br":
+		encoder = brotli.NewWriter(&buf)
+	case "raw-deflate":
+		encoder, err = flate.NewWriter(&buf, flate.DefaultCompression)
+	default:
+		encoder, err = newBodyEncoder(&buf, coding)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := encoder.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompression(t *testing.T) {
+	payload := []byte(strings.Repeat("a compressible response body\n", 200))
+
+	for _, test := range []struct {
+		name     string
+		codings  []string // Codings applied by the server, in order
+		header   string   // Content-Encoding sent by the server
+		want     string   // Codings removed by the client
+		decoded  bool
+		compress bool // --compressed
+	}{
+		{"gzip", []string{"gzip"}, "gzip", "gzip", true, false},
+		{"x-gzip", []string{"gzip"}, "x-gzip", "x-gzip", true, false},
+		{"deflate", []string{"deflate"}, "deflate", "deflate", true, true},
+		{"raw deflate", []string{"raw-deflate"}, "deflate", "deflate", true, true},
+		{"br", []string{"br"}, "br", "br", true, true},
+		{"zstd", []string{"zstd"}, "zstd", "zstd", true, true},
+		{"stacked", []string{"gzip", "br"}, "gzip, br", "gzip, br", true, true},
+		{"identity", nil, "identity", "", true, false},
+		{"unknown coding", []string{"gzip"}, "compress", "", false, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			body := payload
+			for _, coding := range test.codings {
+				body = encodeWith(t, coding, body)
+			}
+			var acceptEncoding string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				acceptEncoding = r.Header.Get("Accept-Encoding")
+				w.Header().Set("Content-Encoding", test.header)
+				w.Write(body)
+			}))
+			defer server.Close()
+
+			client, err := NewClient(WithCompressed(test.compress))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(&Request{Method: "GET", URL: server.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wantAccept := defaultAcceptEncoding
+			if test.compress {
+				wantAccept = compressedAcceptEncoding
+			}
+			if acceptEncoding != wantAccept {
+				t.Errorf("sent Accept-Encoding %q, want %q", acceptEncoding, wantAccept)
+			}
+			if resp.Encoding != test.want {
+				t.Errorf("got encoding %q, want %q", resp.Encoding, test.want)
+			}
+			if resp.WireSize != int64(len(body)) {
+				t.Errorf("got wire size %d, want %d", resp.WireSize, len(body))
+			}
+			if !test.decoded {
+				// The body is kept as received
+				if resp.Body != string(body) {
+					t.Errorf("got a changed body for an unknown coding")
+				}
+				return
+			}
+			if resp.Body != string(payload) || resp.BodySize != int64(len(payload)) {
+				t.Errorf("got body of %d bytes, want the %d bytes payload", resp.BodySize, len(payload))
+			}
+			if len(test.codings) > 0 && resp.WireSize >= resp.BodySize {
+				t.Errorf("got wire size %d, want less than the body size %d", resp.WireSize, resp.BodySize)
+			}
+		})
+	}
+}
+
+func TestDecompressionAcceptEncodingFromUser(t *testing.T) {
+	body := encodeWith(t, "gzip", []byte("compressed"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("X-Accept-Encoding", r.Header.Get("Accept-Encoding"))
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	for _, compressed := range []bool{false, true} {
+		client, err := NewClient(WithCompressed(compressed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(&Request{Method: "GET", URL: server.URL, Headers: `{"Accept-Encoding": "gzip"}`})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Headers["X-Accept-Encoding"] != "gzip" {
+			t.Errorf("sent Accept-Encoding %q, want the header of the user", resp.Headers["X-Accept-Encoding"])
+		}
+		// The response to a header set by the user is only decoded with --compressed
+		if decoded := resp.Body == "compressed"; decoded != compressed {
+			t.Errorf("got body %q with --compressed %t", resp.Body, compressed)
+		}
+	}
+}
+
+func TestDecompressionInvalidBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write([]byte("not gzip data"))
+	}))
+	defer server.Close()
+
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(&Request{Method: "GET", URL: server.URL}); err == nil || !strings.Contains(err.Error(), "failed to decode gzip body") {
+		t.Errorf("got %v, want a decoding error", err)
+	}
+}
+
+// decodingServer decodes the request body of /upload with its Content-Encoding and
+// answers with it, /redirect sends the body again to /upload
+func decodingServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			io.Copy(io.Discard, r.Body)
+			http.Redirect(w, r, "/upload", http.StatusTemporaryRedirect)
+			return
+		}
+		wire, _ := io.ReadAll(r.Body)
+		decoded, codings, err := decodeBody(bytes.NewReader(wire), r.Header.Get("Content-Encoding"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer decoded.Close()
+		content, err := io.ReadAll(decoded)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("X-Wire-Size", strconv.Itoa(len(wire)))
+		w.Header().Set("X-Content-Length", strconv.FormatInt(r.ContentLength, 10))
+		w.Write([]byte(codings + " " + string(content)))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestBodyCompression(t *testing.T) {
+	server := decodingServer(t)
+	payload := strings.Repeat("a compressible request body\n", 200)
+	file := filepath.Join(t.TempDir(), "body.txt")
+	if err := os.WriteFile(file, []byte(payload), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, encoding := range []string{"gzip", "zstd", "deflate"} {
+		for _, test := range []struct {
+			name    string
+			body    string
+			path    string
+			chunked bool // Streamed bodies are compressed as they are sent
+		}{
+			{"text", payload, "/upload", false},
+			{"text redirected", payload, "/redirect", false},
+			{"file", "@" + file, "/upload", true},
+			{"file redirected", "@" + file, "/redirect", true},
+		} {
+			t.Run(encoding+" "+test.name, func(t *testing.T) {
+				client, err := NewClient()
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp, err := client.Do(&Request{Method: "POST", URL: server.URL + test.path, BodyType: "text", Body: test.body, CompressBody: encoding})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.Body != encoding+" "+payload {
+					t.Fatalf("server got %.40q, want the payload compressed with %s", resp.Body, encoding)
+				}
+
+				wire, _ := strconv.ParseInt(resp.Headers["X-Wire-Size"], 10, 64)
+				contentLength := resp.Headers["X-Content-Length"]
+				if test.chunked != (contentLength == "-1") {
+					t.Errorf("got content length %s for the %s body", contentLength, test.name)
+				}
+				stats := resp.RequestCompression
+				if stats == nil {
+					t.Fatal("no request compression reported")
+				}
+				if stats.Encoding != encoding || stats.OriginalSize != int64(len(payload)) || stats.CompressedSize != wire {
+					t.Errorf("got %+v, want %s from %d to %d bytes", *stats, encoding, len(payload), wire)
+				}
+				if wire >= int64(len(payload)) {
+					t.Errorf("got %d bytes on the wire for %d bytes of body", wire, len(payload))
+				}
+			})
+		}
+	}
+}
+
+func TestBodyCompressionMultipart(t *testing.T) {
+	server := decodingServer(t)
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(&Request{Method: "POST", URL: server.URL + "/upload", BodyType: "multipart", Fields: []string{"name=Ada"}, CompressBody: "gzip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(resp.Body, "gzip --") || !strings.Contains(resp.Body, "name=\"name\"\r\n\r\nAda\r\n") {
+		t.Errorf("server got %q, want the multipart body compressed with gzip", resp.Body)
+	}
+}
+
+func TestBodyCompressionUnsupported(t *testing.T) {
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Do(&Request{Method: "POST", URL: "http://127.0.0.1:1", BodyType: "text", Body: "data", CompressBody: "br"})
+	if err == nil || !strings.Contains(err.Error(), "unsupported body compression: br") {
+		t.Errorf("got %v, want an unsupported compression error", err)
+	}
+}
@@ -0,0 +1,103 @@
+package http
+
+import (
+	"net"
+	"net/http/httptrace"
+	"time"
+)
+
+// ConnectionInfo describes the connection that carried the response
+type ConnectionInfo struct {
+	ResolvedAddrs []string      `json:"resolved_addrs,omitempty"` // Addresses returned by the DNS lookup
+	DialAttempts  []DialAttempt `json:"dial_attempts,omitempty"`  // Every connection attempt, happy eyeballs fallbacks included
+	LocalAddr     string        `json:"local_addr,omitempty"`     // Local socket address
+	RemoteAddr    string        `json:"remote_addr,omitempty"`    // Remote socket address, the proxy when one is used
+	Reused        bool          `json:"reused"`                   // Connection was already used by a previous request
+	WasIdle       bool          `json:"was_idle"`                 // Connection was taken from the idle pool
+	IdleTime      time.Duration `json:"idle_time,omitempty"`      // Time the connection spent idle before this request
+}
+
+// DialAttempt is a single connection attempt to one address
+type DialAttempt struct {
+	Network  string        `json:"network"`
+	Addr     string        `json:"addr"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"` // Empty when the connection succeeded
+}
+
+// dnsResolved records the addresses returned by the DNS lookup
+func (t *requestTrace) dnsResolved(addrs []net.IPAddr) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, addr := range addrs {
+		t.conn.ResolvedAddrs = append(t.conn.ResolvedAddrs, addr.String())
+	}
+}
+
+// dialStarted records the start of a connection attempt, several attempts may run in parallel
+func (t *requestTrace) dialStarted(network, addr string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.conn.DialAttempts = append(t.conn.DialAttempts, DialAttempt{Network: network, Addr: addr})
+	t.dialStarts = append(t.dialStarts, time.Now())
+}
+
+// dialDone records the outcome of the pending connection attempt to addr
+func (t *requestTrace) dialDone(network, addr string, err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := len(t.conn.DialAttempts) - 1; i >= 0; i-- {
+		attempt := &t.conn.DialAttempts[i]
+		if attempt.Network != network || attempt.Addr != addr || attempt.Duration != 0 {
+			continue
+		}
+		attempt.Duration = time.Since(t.dialStarts[i])
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+		return
+	}
+}
+
+// gotConn records the connection obtained for the request
+func (t *requestTrace) gotConn(info httptrace.GotConnInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.conn.Reused, t.conn.WasIdle, t.conn.IdleTime = info.Reused, info.WasIdle, info.IdleTime
+	if info.Conn != nil {
+		t.conn.LocalAddr, t.conn.RemoteAddr = info.Conn.LocalAddr().String(), info.Conn.RemoteAddr().String()
+	}
+}
+
+// gotQUICConn records the addresses of a QUIC connection, which httptrace does not report
+func (t *requestTrace) gotQUICConn(local, remote net.Addr) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.conn.LocalAddr, t.conn.RemoteAddr = local.String(), remote.String()
+}
+
+// resetConn forgets the connection details of the previous redirect hop
+func (t *requestTrace) resetConn() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.conn, t.dialStarts = ConnectionInfo{}, nil
+}
+
+// connectionInfo returns a copy of the connection details recorded so far
+func (t *requestTrace) connectionInfo() *ConnectionInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	info := t.conn
+	info.ResolvedAddrs = append([]string(nil), t.conn.ResolvedAddrs...)
+	info.DialAttempts = append([]DialAttempt(nil), t.conn.DialAttempts...)
+	return &info
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestConnectionInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.RemoteAddr))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://localhost:" + port
+	first, err := client.Do(&Request{Method: "GET", URL: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := first.Connection
+	if !slices.Contains(info.ResolvedAddrs, "127.0.0.1") {
+		t.Errorf("got resolved addresses %v, want 127.0.0.1 among them", info.ResolvedAddrs)
+	}
+	if len(info.DialAttempts) == 0 {
+		t.Fatal("no dial attempt recorded")
+	}
+	last := info.DialAttempts[len(info.DialAttempts)-1]
+	if last.Addr != server.Listener.Addr().String() || last.Error != "" || last.Duration <= 0 {
+		t.Errorf("got last dial attempt %+v, want a success to %s", last, server.Listener.Addr())
+	}
+	// The server saw the local address of the client
+	if info.RemoteAddr != server.Listener.Addr().String() || info.LocalAddr != first.Body || info.Reused {
+		t.Errorf("got %+v, want a new connection from %s", info, first.Body)
+	}
+
+	second, err := client.Do(&Request{Method: "GET", URL: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info = second.Connection
+	if !info.Reused || !info.WasIdle || len(info.DialAttempts) != 0 || info.LocalAddr != first.Connection.LocalAddr {
+		t.Errorf("got %+v, want the idle connection of the first request", info)
+	}
+}
+
+func TestConnectionInfoFailedAttempt(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// The server only listens on 127.0.0.1, the first pinned address is refused
+	client, err := NewClient(
+		WithConnectTo("api.test:80:api.test:"+port),
+		WithResolve("api.test:"+port+":127.0.0.2,127.0.0.1"),
+		WithConnectTimeout(time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(&Request{Method: "GET", URL: "http://api.test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := resp.Connection.DialAttempts
+	if len(attempts) != 2 || attempts[0].Error == "" || attempts[1].Error != "" {
+		t.Fatalf("got dial attempts %+v, want a failure then a success", attempts)
+	}
+	if attempts[0].Addr != "127.0.0.2:"+port || attempts[1].Addr != "127.0.0.1:"+port {
+		t.Errorf("got attempts to %s and %s", attempts[0].Addr, attempts[1].Addr)
+	}
+	if len(resp.Connection.ResolvedAddrs) != 0 {
+		t.Errorf("got resolved addresses %v for a pinned host", resp.Connection.ResolvedAddrs)
+	}
+}
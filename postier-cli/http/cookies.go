@@ -0,0 +1,402 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Cookie jar file formats
+const (
+	CookieFormatNetscape = "netscape" // cookies.txt as written by curl and browser extensions
+	CookieFormatJSON     = "json"     // JSON array of JarCookie
+)
+
+// JarCookie is a cookie stored in a CookieJar
+type JarCookie struct {
+	Domain   string `json:"domain"`
+	HostOnly bool   `json:"host_only"` // Only sent to Domain itself, not to its subdomains
+	Path     string `json:"path"`
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Expires  int64  `json:"expires,omitempty"` // Unix time, 0 for a session cookie
+	Secure   bool   `json:"secure,omitempty"`
+	HttpOnly bool   `json:"http_only,omitempty"`
+}
+
+// expired reports whether the cookie expired at now
+func (c *JarCookie) expired(now time.Time) bool {
+	return c.Expires != 0 && c.Expires <= now.Unix()
+}
+
+// CookieJar is an http.CookieJar kept in a file between runs. Session cookies are
+// saved too, so a login done by one command is used by the next ones.
+type CookieJar struct {
+	mu      sync.Mutex
+	path    string
+	format  string
+	cookies []*JarCookie
+}
+
+// WithCookieJar sends the cookies of jar with the requests and stores the cookies set by the responses
+func WithCookieJar(jar *CookieJar) Option {
+	return func(c *Client) {
+		c.cookieJar = jar
+	}
+}
+
+// SaveCookies writes the cookie jar back to its file, it does nothing without a jar
+func (c *Client) SaveCookies() error {
+	if c.cookieJar == nil {
+		return nil
+	}
+	return c.cookieJar.Save()
+}
+
+// LoadCookieJar reads the cookie jar at path, in Netscape or JSON format. A missing
+// file gives an empty jar, saved in JSON when path ends with .json and in Netscape
+// format otherwise.
+func LoadCookieJar(path string) (*CookieJar, error) {
+	jar := &CookieJar{path: path, format: CookieFormatNetscape}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		jar.format = CookieFormatJSON
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return jar, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cookie jar: %w", err)
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case len(trimmed) == 0:
+	case trimmed[0] == '[':
+		jar.format = CookieFormatJSON
+		if err := json.Unmarshal(trimmed, &jar.cookies); err != nil {
+			return nil, fmt.Errorf("invalid cookie jar %s: %w", path, err)
+		}
+	default:
+		jar.format = CookieFormatNetscape
+		if jar.cookies, err = parseNetscapeCookies(data); err != nil {
+			return nil, fmt.Errorf("invalid cookie jar %s: %w", path, err)
+		}
+	}
+
+	// Expired cookies are dropped as soon as the jar is loaded
+	now := time.Now()
+	kept := jar.cookies[:0]
+	for _, cookie := range jar.cookies {
+		if !cookie.expired(now) {
+			kept = append(kept, cookie)
+		}
+	}
+	jar.cookies = kept
+	return jar, nil
+}
+
+// parseNetscapeCookies parses a cookies.txt file: one cookie per line with tab separated
+// domain, include subdomains, path, secure, expires, name and value
+func parseNetscapeCookies(data []byte) ([]*JarCookie, error) {
+	var cookies []*JarCookie
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			line, httpOnly = strings.TrimPrefix(line, "#HttpOnly_"), true
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d: expected 7 tab separated fields, got %d", number, len(fields))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expiration time %q", number, fields[4])
+		}
+		cookies = append(cookies, &JarCookie{
+			Domain:   strings.ToLower(strings.TrimPrefix(fields[0], ".")),
+			HostOnly: !strings.EqualFold(fields[1], "TRUE") && !strings.HasPrefix(fields[0], "."),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Expires:  expires,
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		})
+	}
+	return cookies, scanner.Err()
+}
+
+// Path returns the file the jar is saved to
+func (j *CookieJar) Path() string {
+	return j.path
+}
+
+// Save writes the jar to its file in the format it was read with. The file holds
+// credentials, it is only readable by the user.
+func (j *CookieJar) Save() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var buf bytes.Buffer
+	now := time.Now()
+	switch j.format {
+	case CookieFormatJSON:
+		cookies := make([]*JarCookie, 0, len(j.cookies))
+		for _, cookie := range j.cookies {
+			if !cookie.expired(now) {
+				cookies = append(cookies, cookie)
+			}
+		}
+		data, err := json.MarshalIndent(cookies, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode cookie jar: %w", err)
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	default:
+		buf.WriteString("# Netscape HTTP Cookie File\n# Written by postier, edit at your own risk\n\n")
+		for _, cookie := range j.cookies {
+			if cookie.expired(now) {
+				continue
+			}
+			domain, subdomains := cookie.Domain, "FALSE"
+			if !cookie.HostOnly {
+				domain, subdomains = "."+domain, "TRUE"
+			}
+			if cookie.HttpOnly {
+				domain = "#HttpOnly_" + domain
+			}
+			secure := "FALSE"
+			if cookie.Secure {
+				secure = "TRUE"
+			}
+			fmt.Fprintf(&buf, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+				domain, subdomains, cookie.Path, secure, cookie.Expires, cookie.Name, cookie.Value)
+		}
+	}
+
+	// Write a temporary file first so an interrupted save keeps the previous jar
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), filepath.Base(j.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save cookie jar: %w", err)
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save cookie jar: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save cookie jar: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), j.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save cookie jar: %w", err)
+	}
+	return nil
+}
+
+// All returns the cookies of the jar that did not expire, sorted by domain, path and name
+func (j *CookieJar) All() []JarCookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	var cookies []JarCookie
+	for _, cookie := range j.cookies {
+		if !cookie.expired(now) {
+			cookies = append(cookies, *cookie)
+		}
+	}
+	sort.Slice(cookies, func(a, b int) bool {
+		if cookies[a].Domain != cookies[b].Domain {
+			return cookies[a].Domain < cookies[b].Domain
+		}
+		if cookies[a].Path != cookies[b].Path {
+			return cookies[a].Path < cookies[b].Path
+		}
+		return cookies[a].Name < cookies[b].Name
+	})
+	return cookies
+}
+
+// Clear removes the cookies of domain and its subdomains, or every cookie when domain
+// is empty, and returns how many were removed
+func (j *CookieJar) Clear(domain string) int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	kept := j.cookies[:0]
+	for _, cookie := range j.cookies {
+		if domain != "" && !domainMatch(cookie.Domain, domain) {
+			kept = append(kept, cookie)
+		}
+	}
+	removed := len(j.cookies) - len(kept)
+	j.cookies = kept
+	return removed
+}
+
+// SetCookies stores the cookies set by a response from u, following RFC 6265
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+	for _, cookie := range cookies {
+		stored := &JarCookie{
+			Domain:   host,
+			HostOnly: true,
+			Path:     cookie.Path,
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+		}
+
+		if cookie.Domain != "" {
+			domain := strings.ToLower(strings.TrimPrefix(cookie.Domain, "."))
+			// A server may only set cookies for its own domain, and an IP address has no subdomains
+			if !domainMatch(host, domain) || (net.ParseIP(host) != nil && domain != host) {
+				continue
+			}
+			// A public suffix such as co.uk would send the cookie to every site under it,
+			// it is only accepted from the suffix itself and kept for that host only
+			if isPublicSuffix(domain) && host != domain {
+				continue
+			}
+			if net.ParseIP(host) == nil && !isPublicSuffix(domain) {
+				stored.Domain, stored.HostOnly = domain, false
+			}
+		}
+		if !strings.HasPrefix(stored.Path, "/") {
+			stored.Path = defaultCookiePath(u.Path)
+		}
+
+		deleted := false
+		switch {
+		case cookie.MaxAge < 0:
+			deleted = true
+		case cookie.MaxAge > 0:
+			stored.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second).Unix()
+		case !cookie.Expires.IsZero():
+			stored.Expires = cookie.Expires.Unix()
+			deleted = !cookie.Expires.After(now)
+		}
+
+		// A cookie replaces the one with the same domain, path and name
+		replaced := false
+		for i, existing := range j.cookies {
+			if existing.Domain != stored.Domain || existing.Path != stored.Path || existing.Name != stored.Name {
+				continue
+			}
+			if deleted {
+				j.cookies = append(j.cookies[:i], j.cookies[i+1:]...)
+			} else {
+				j.cookies[i] = stored
+			}
+			replaced = true
+			break
+		}
+		if !replaced && !deleted {
+			j.cookies = append(j.cookies, stored)
+		}
+	}
+}
+
+// Cookies returns the cookies to send in a request to u, the most specific paths first
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := strings.ToLower(u.Hostname())
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	secure := u.Scheme == "https" || u.Scheme == "wss"
+	now := time.Now()
+
+	var matched []*JarCookie
+	for _, cookie := range j.cookies {
+		if cookie.expired(now) || (cookie.Secure && !secure) || !pathMatch(path, cookie.Path) {
+			continue
+		}
+		if (cookie.HostOnly && host != cookie.Domain) || (!cookie.HostOnly && !domainMatch(host, cookie.Domain)) {
+			continue
+		}
+		matched = append(matched, cookie)
+	}
+	sort.SliceStable(matched, func(a, b int) bool {
+		return len(matched[a].Path) > len(matched[b].Path)
+	})
+
+	cookies := make([]*http.Cookie, 0, len(matched))
+	for _, cookie := range matched {
+		cookies = append(cookies, &http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	}
+	return cookies
+}
+
+// ParseCookie parses a cookie given as name=value
+func ParseCookie(spec string) (*http.Cookie, error) {
+	name, value, found := strings.Cut(spec, "=")
+	name = strings.TrimSpace(name)
+	if !found || name == "" {
+		return nil, fmt.Errorf("invalid cookie %q, expected name=value", spec)
+	}
+	return &http.Cookie{Name: name, Value: strings.TrimSpace(value)}, nil
+}
+
+// isPublicSuffix reports whether domain is a public suffix under which anyone can register
+// a name, such as com or co.uk. A single label domain is always one.
+func isPublicSuffix(domain string) bool {
+	suffix, _ := publicsuffix.PublicSuffix(domain)
+	return suffix == domain || !strings.Contains(domain, ".")
+}
+
+// domainMatch reports whether host is domain or one of its subdomains
+func domainMatch(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// pathMatch reports whether a request path is within a cookie path
+func pathMatch(path, cookiePath string) bool {
+	if !strings.HasPrefix(path, cookiePath) {
+		return false
+	}
+	return len(path) == len(cookiePath) || strings.HasSuffix(cookiePath, "/") || path[len(cookiePath)] == '/'
+}
+
+// defaultCookiePath returns the path of a cookie set without a Path attribute
+func defaultCookiePath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return path[:i]
+}
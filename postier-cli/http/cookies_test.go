@@ -0,0 +1,55 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestCookieJarDomain(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		from   string // URL of the response setting the cookie
+		domain string // Domain attribute
+		sentTo map[string]bool
+	}{
+		{"host only", "https://www.example.com/", "", map[string]bool{
+			"https://www.example.com/": true, "https://api.example.com/": false,
+		}},
+		{"parent domain", "https://www.example.com/", "example.com", map[string]bool{
+			"https://www.example.com/": true, "https://api.example.com/": true, "https://example.org/": false,
+		}},
+		{"public suffix", "https://evil.co.uk/", "co.uk", map[string]bool{
+			"https://evil.co.uk/": false, "https://bank.co.uk/": false, "https://co.uk/": false,
+		}},
+		{"top level domain", "https://evil.com/", ".com", map[string]bool{
+			"https://evil.com/": false, "https://bank.com/": false,
+		}},
+		{"public suffix host", "https://github.io/", "github.io", map[string]bool{
+			"https://github.io/": true, "https://user.github.io/": false,
+		}},
+		{"single label host", "http://localhost/", "localhost", map[string]bool{
+			"http://localhost/": true,
+		}},
+		{"other domain", "https://www.example.com/", "example.org", map[string]bool{
+			"https://www.example.com/": false, "https://example.org/": false,
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			jar, err := LoadCookieJar(filepath.Join(t.TempDir(), "cookies.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			from, _ := url.Parse(test.from)
+			jar.SetCookies(from, []*http.Cookie{{Name: "session", Value: "1", Domain: test.domain}})
+
+			for target, want := range test.sentTo {
+				u, _ := url.Parse(target)
+				if got := len(jar.Cookies(u)) == 1; got != want {
+					t.Errorf("cookie sent to %s: %t, want %t", target, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// EncodeForm encodes key=value pairs as an application/x-www-form-urlencoded body,
+// keeping their order. A key given several times is sent several times.
+func EncodeForm(pairs []string) (string, error) {
+	encoded := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		if !found || key == "" {
+			return "", fmt.Errorf("invalid form field %q, expected key=value", pair)
+		}
+		encoded = append(encoded, url.QueryEscape(key)+"="+url.QueryEscape(value))
+	}
+	return strings.Join(encoded, "&"), nil
+}
+
+// encodeFormBody turns the input of a form body into its encoded form. A JSON object is
+// encoded field by field, arrays giving repeated keys, anything else must already be encoded.
+func encodeFormBody(content []byte) (string, error) {
+	trimmed := bytes.TrimSpace(content)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return encodeJSONForm(trimmed)
+	}
+
+	encoded := strings.TrimRight(string(content), "\r\n")
+	if _, err := url.ParseQuery(encoded); err != nil {
+		return "", fmt.Errorf("invalid form body: %w", err)
+	}
+	return encoded, nil
+}
+
+// encodeJSONForm encodes a JSON object as a form body, in the order of its fields
+func encodeJSONForm(content []byte) (string, error) {
+	if err := validateJSON(bytes.NewReader(content)); err != nil {
+		return "", fmt.Errorf("invalid JSON form body: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if _, err := decoder.Token(); err != nil { // Opening brace
+		return "", fmt.Errorf("invalid JSON form body: %w", err)
+	}
+
+	var pairs []string
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("invalid JSON form body: %w", err)
+		}
+		key := token.(string)
+
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return "", fmt.Errorf("invalid JSON form body: %w", err)
+		}
+
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+		for _, value := range values {
+			text, err := formValue(value)
+			if err != nil {
+				return "", fmt.Errorf("invalid JSON form body: field %s: %w", key, err)
+			}
+			pairs = append(pairs, key+"="+text)
+		}
+	}
+
+	return EncodeForm(pairs)
+}
+
+// formValue converts a JSON scalar to its form value
+func formValue(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return fmt.Sprint(value), nil
+	}
+	return "", fmt.Errorf("nested objects and arrays cannot be form encoded")
+}
@@ -0,0 +1,148 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncodeForm(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		pairs   []string
+		want    string
+		wantErr string
+	}{
+		{"order kept", []string{"b=2", "a=1"}, "b=2&a=1", ""},
+		{"repeated key", []string{"tag=a", "tag=b"}, "tag=a&tag=b", ""},
+		{"escaping", []string{"full name=John Doe", "q=a&b=c", "path=/x?y#z"}, "full+name=John+Doe&q=a%26b%3Dc&path=%2Fx%3Fy%23z", ""},
+		{"equal sign in value", []string{"expr=a=b"}, "expr=a%3Db", ""},
+		{"empty value", []string{"empty="}, "empty=", ""},
+		{"no pairs", nil, "", ""},
+		{"missing equal sign", []string{"name"}, "", `invalid form field "name"`},
+		{"missing key", []string{"=value"}, "", `invalid form field "=value"`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := EncodeForm(test.pairs)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("got %v, want an error with %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEncodeFormBody(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{"encoded", "a=1&b=x+y\n", "a=1&b=x+y", ""},
+		{"json object", `{"name": "John Doe", "age": 42, "admin": false, "note": null}`, "name=John+Doe&age=42&admin=false&note=", ""},
+		{"json array", ` {"tag": ["a", "b"], "id": 1.50}`, "tag=a&tag=b&id=1.50", ""},
+		{"json nested object", `{"user": {"name": "John"}}`, "", "field user: nested objects and arrays cannot be form encoded"},
+		{"json nested array", `{"tags": [["a"]]}`, "", "field tags: nested objects and arrays cannot be form encoded"},
+		{"invalid json", `{"name": }`, "", "invalid JSON form body"},
+		{"invalid encoding", "a=%zz", "", "invalid form body"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := encodeFormBody([]byte(test.content))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("got %v, want an error with %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFormRequest(t *testing.T) {
+	// The server decodes the form and answers with its values in order
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(strings.NewReader(string(raw)))
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, "%s\n%s\n%q %q", r.Header.Get("Content-Type"), raw, r.PostForm.Get("name"), r.PostForm["tag"])
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "form.json")
+	if err := os.WriteFile(file, []byte(`{"name": "John Doe", "tag": ["a", "b&c"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "application/x-www-form-urlencoded\nname=John+Doe&tag=a&tag=b%26c\n" + `"John Doe" ["a" "b&c"]`
+	for _, test := range []struct {
+		name string
+		req  Request
+	}{
+		{"fields", Request{BodyType: "form", Form: []string{"name=John Doe", "tag=a", "tag=b&c"}}},
+		{"encoded body", Request{BodyType: "form", Body: "name=John+Doe&tag=a&tag=b%26c"}},
+		{"json file", Request{BodyType: "form", Body: "@" + file}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := NewClient()
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.req.Method = "POST"
+			test.req.URL = server.URL
+			resp, err := client.Do(&test.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Body != want {
+				t.Errorf("server got:\n%s\nwant:\n%s", resp.Body, want)
+			}
+		})
+	}
+}
+
+func TestFormRequestInvalid(t *testing.T) {
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name    string
+		req     Request
+		wantErr string
+	}{
+		{"fields and body", Request{BodyType: "form", Form: []string{"a=b"}, Body: "c=d"}, "either from its fields or from a body"},
+		{"invalid field", Request{BodyType: "form", Form: []string{"a"}}, `invalid form field "a"`},
+		{"invalid body", Request{BodyType: "form", Body: "a=%zz"}, "invalid form body"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.req.Method = "POST"
+			test.req.URL = "http://127.0.0.1:1"
+			if _, err := client.Do(&test.req); err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("got %v, want an error with %q", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// newHTTP3Transport builds a transport sending requests over QUIC.
+// The QUIC handshake replaces the TCP connection and TLS handshake phases.
+func (c *Client) newHTTP3Transport(tlsConfig *tls.Config) *http3.Transport {
+	return &http3.Transport{
+		TLSClientConfig:    tlsConfig,
+		DisableCompression: true,
+		Dial: func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (*quic.Conn, error) {
+			// There is no TCP connection, the connect limit only covers the DNS lookup
+			dnsCtx := ctx
+			if c.connectTimeout > 0 {
+				var cancel context.CancelFunc
+				dnsCtx, cancel = context.WithTimeout(ctx, c.connectTimeout)
+				defer cancel()
+			}
+			resolved, err := c.resolveAddr(dnsCtx, addr)
+			if err != nil {
+				return nil, err
+			}
+			udpAddr, err := net.ResolveUDPAddr(c.ipNetwork("udp"), resolved)
+			if err != nil {
+				return nil, err
+			}
+
+			trace := contextRequestTrace(ctx)
+			trace.quicHandshakeStart()
+			if c.tlsTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, c.tlsTimeout)
+				defer cancel()
+			}
+			trace.dialStarted("udp", udpAddr.String())
+			conn, err := dialQUIC(ctx, udpAddr, tlsCfg, cfg)
+			trace.dialDone("udp", udpAddr.String(), err)
+			if err != nil {
+				return nil, err
+			}
+			trace.quicHandshakeDone()
+			trace.gotQUICConn(conn.LocalAddr(), conn.RemoteAddr())
+
+			return conn, nil
+		},
+	}
+}
+
+// dialQUIC opens a QUIC connection over a UDP socket of its own, the socket is closed
+// with the connection: when the transport closes it, or when it fails or times out
+func dialQUIC(ctx context.Context, addr *net.UDPAddr, tlsConfig *tls.Config, config *quic.Config) (*quic.Conn, error) {
+	udpConn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open UDP socket: %w", err)
+	}
+	quicTransport := &quic.Transport{Conn: udpConn}
+	closeSocket := func() {
+		quicTransport.Close()
+		udpConn.Close()
+	}
+
+	conn, err := quicTransport.Dial(ctx, addr, tlsConfig, config)
+	if err != nil {
+		closeSocket()
+		return nil, err
+	}
+	go func() {
+		<-conn.Context().Done()
+		closeSocket()
+	}()
+	return conn, nil
+}
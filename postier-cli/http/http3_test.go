@@ -0,0 +1,83 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// selfSignedCert returns a certificate for 127.0.0.1
+func selfSignedCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// startHTTP3Server serves handler over QUIC on a local UDP port and returns its URL
+func startHTTP3Server(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http3.Server{
+		Handler:   handler,
+		TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: []tls.Certificate{selfSignedCert(t)}}),
+	}
+	go server.Serve(udpConn)
+	t.Cleanup(func() {
+		server.Close()
+		udpConn.Close()
+	})
+	return "https://" + udpConn.LocalAddr().String() + "/"
+}
+
+func TestHTTP3Timings(t *testing.T) {
+	url := startHTTP3Server(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("over quic"))
+	}))
+
+	client, err := NewClient(WithHTTPVersion(HTTPVersion3), WithInsecure(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	resp, err := client.Do(&Request{Method: "GET", URL: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Protocol != "HTTP/3.0" || resp.Body != "over quic" {
+		t.Fatalf("got %s %q, want HTTP/3.0 \"over quic\"", resp.Protocol, resp.Body)
+	}
+	if resp.Timings.QUICHandshake <= 0 {
+		t.Errorf("QUIC handshake time not recorded")
+	}
+	if resp.Timings.TCPConnection != 0 || resp.Timings.TLSHandshake != 0 {
+		t.Errorf("got TCP %s and TLS %s, want no TCP or TLS phase over QUIC", resp.Timings.TCPConnection, resp.Timings.TLSHandshake)
+	}
+}
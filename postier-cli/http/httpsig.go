@@ -0,0 +1,579 @@
+package http
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Algorithms of HTTP message signatures (RFC 9421 section 3.3)
+const (
+	SignatureHMACSHA256   = "hmac-sha256"
+	SignatureEd25519      = "ed25519"
+	SignatureRSAPSSSHA512 = "rsa-pss-sha512"
+)
+
+// DefaultSignatureLabel names the signature in the Signature and Signature-Input headers
+const DefaultSignatureLabel = "sig1"
+
+// DefaultSignatureMaxAge is the age of the oldest response signature the CLI accepts
+const DefaultSignatureMaxAge = 5 * time.Minute
+
+// signatureClockSkew is how far in the future a signature may be created, for clocks out of sync
+const signatureClockSkew = time.Minute
+
+// SignatureKey is a key signing or verifying HTTP message signatures: an HMAC secret,
+// an Ed25519 key or an RSA key
+type SignatureKey struct {
+	ID        string // Sent in the keyid parameter, left out when empty
+	Algorithm string // hmac-sha256, ed25519 or rsa-pss-sha512
+
+	secret  []byte
+	private crypto.Signer    // Nil for a public key, which only verifies
+	public  crypto.PublicKey // Nil for an HMAC secret
+}
+
+// LoadSignatureKey reads a key given as @file or env:VAR, see ParseSignatureKey
+func LoadSignatureKey(ref, algorithm string) (*SignatureKey, error) {
+	var data []byte
+	switch {
+	case strings.HasPrefix(ref, "@"):
+		var err error
+		if data, err = os.ReadFile(ref[1:]); err != nil {
+			return nil, fmt.Errorf("failed to read signature key: %w", err)
+		}
+	case strings.HasPrefix(ref, "env:"):
+		value := os.Getenv(ref[len("env:"):])
+		if value == "" {
+			return nil, fmt.Errorf("environment variable %s is empty", ref[len("env:"):])
+		}
+		data = []byte(value)
+	default:
+		return nil, fmt.Errorf("invalid signature key %q, give it as @file or env:VAR", ref)
+	}
+	return ParseSignatureKey(data, algorithm)
+}
+
+// ParseSignatureKey parses a PEM Ed25519 or RSA key, private (PKCS#8, PKCS#1) or public (PKIX),
+// any other data is an HMAC secret. The algorithm follows from the key when it is empty.
+func ParseSignatureKey(data []byte, algorithm string) (*SignatureKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		if algorithm != "" && algorithm != SignatureHMACSHA256 {
+			return nil, fmt.Errorf("%s needs a PEM key", algorithm)
+		}
+		secret := []byte(strings.TrimSpace(string(data)))
+		if len(secret) == 0 {
+			return nil, fmt.Errorf("empty HMAC secret")
+		}
+		return &SignatureKey{Algorithm: SignatureHMACSHA256, secret: secret}, nil
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %s in signature key", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature key: %w", err)
+	}
+
+	key := &SignatureKey{}
+	switch k := parsed.(type) {
+	case ed25519.PrivateKey:
+		key.Algorithm, key.private, key.public = SignatureEd25519, k, k.Public()
+	case ed25519.PublicKey:
+		key.Algorithm, key.public = SignatureEd25519, k
+	case *rsa.PrivateKey:
+		key.Algorithm, key.private, key.public = SignatureRSAPSSSHA512, k, k.Public()
+	case *rsa.PublicKey:
+		key.Algorithm, key.public = SignatureRSAPSSSHA512, k
+	default:
+		return nil, fmt.Errorf("unsupported signature key type %T", parsed)
+	}
+	if algorithm != "" && algorithm != key.Algorithm {
+		return nil, fmt.Errorf("signature key is for %s, not %s", key.Algorithm, algorithm)
+	}
+	return key, nil
+}
+
+// sign signs the signature base with the algorithm of the key
+func (k *SignatureKey) sign(base []byte) ([]byte, error) {
+	switch {
+	case k.Algorithm == SignatureHMACSHA256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(base)
+		return mac.Sum(nil), nil
+	case k.private == nil:
+		return nil, fmt.Errorf("a public key cannot sign, give the private key")
+	case k.Algorithm == SignatureEd25519:
+		return k.private.Sign(rand.Reader, base, crypto.Hash(0))
+	}
+	digest := sha512.Sum512(base)
+	return k.private.Sign(rand.Reader, digest[:], &rsa.PSSOptions{SaltLength: 64, Hash: crypto.SHA512})
+}
+
+// verify checks a signature of the signature base
+func (k *SignatureKey) verify(base, signature []byte) error {
+	switch k.Algorithm {
+	case SignatureHMACSHA256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(base)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return fmt.Errorf("signature does not match")
+		}
+	case SignatureEd25519:
+		if !ed25519.Verify(k.public.(ed25519.PublicKey), base, signature) {
+			return fmt.Errorf("signature does not match")
+		}
+	default:
+		digest := sha512.Sum512(base)
+		if err := rsa.VerifyPSS(k.public.(*rsa.PublicKey), crypto.SHA512, digest[:], signature, &rsa.PSSOptions{SaltLength: 64}); err != nil {
+			return fmt.Errorf("signature does not match")
+		}
+	}
+	return nil
+}
+
+// MessageSignature signs requests with HTTP Message Signatures (RFC 9421)
+type MessageSignature struct {
+	Key   *SignatureKey
+	Label string // Name of the signature, sig1 when empty
+
+	// Components covered by the signature, as derived components such as @method, @target-uri,
+	// @authority, @path, @query and @query-param;name=id, or header names. A content-digest
+	// component adds the Content-Digest of the body. When empty, @method, @target-uri and,
+	// for a request with a body, content-type and content-digest are covered.
+	Components []string
+}
+
+// SignatureVerification is the outcome of the verification of a response signature
+type SignatureVerification struct {
+	Verified   bool     `json:"verified"`
+	Label      string   `json:"label,omitempty"`
+	KeyID      string   `json:"keyid,omitempty"`
+	Algorithm  string   `json:"alg,omitempty"`
+	Components []string `json:"components,omitempty"`
+	Error      string   `json:"error,omitempty"` // Why no signature could be verified
+}
+
+// sign adds the Signature-Input and Signature headers of the request, with its Content-Digest
+// when it is covered. It runs once the request is built so the signature covers what is sent.
+func (s *MessageSignature) sign(req *http.Request, now time.Time) error {
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	specs := s.Components
+	if len(specs) == 0 {
+		specs = []string{"@method", "@target-uri"}
+		if hasBody {
+			if req.Header.Get("Content-Type") != "" {
+				specs = append(specs, "content-type")
+			}
+			specs = append(specs, "content-digest")
+		}
+	}
+	components := make([]sfItem, 0, len(specs))
+	for _, spec := range specs {
+		component, err := parseComponentSpec(spec)
+		if err != nil {
+			return err
+		}
+		components = append(components, component)
+	}
+
+	for _, component := range components {
+		if component.value == "content-digest" && req.Header.Get("Content-Digest") == "" {
+			hasher := sha256.New()
+			if err := hashBody(req, hasher, "Content-Digest"); err != nil {
+				return err
+			}
+			req.Header.Set("Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(hasher.Sum(nil))+":")
+		}
+	}
+
+	params := []sfParam{{"created", now.Unix()}}
+	if s.Key.ID != "" {
+		params = append(params, sfParam{"keyid", s.Key.ID})
+	}
+	params = append(params, sfParam{"alg", s.Key.Algorithm})
+	signatureParams := serializeInnerList(components, params)
+
+	base, err := signatureBase(&signedMessage{request: req, header: req.Header}, components, signatureParams)
+	if err != nil {
+		return err
+	}
+	signature, err := s.Key.sign(base)
+	if err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	label := s.Label
+	if label == "" {
+		label = DefaultSignatureLabel
+	}
+	req.Header.Set("Signature-Input", label+"="+signatureParams)
+	req.Header.Set("Signature", label+"=:"+base64.StdEncoding.EncodeToString(signature)+":")
+	return nil
+}
+
+// bodyDigests hashes a response body as it is received, before its content codings are removed
+type bodyDigests struct {
+	sha256 hash.Hash
+	sha512 hash.Hash
+	size   int64
+}
+
+func newBodyDigests() *bodyDigests {
+	return &bodyDigests{sha256: sha256.New(), sha512: sha512.New()}
+}
+
+func (d *bodyDigests) Write(p []byte) (int, error) {
+	d.sha256.Write(p)
+	d.sha512.Write(p)
+	d.size += int64(len(p))
+	return len(p), nil
+}
+
+// verifyResponseSignature verifies the signatures of a response with key, one valid signature is
+// enough. Signatures of another keyid or algorithm are skipped. A signature must cover the
+// Content-Digest of a response with a body, and that digest must match the body received.
+// Signatures created more than maxAge ago are rejected, zero accepts any age.
+func verifyResponseSignature(resp *http.Response, key *SignatureKey, maxAge time.Duration, digests *bodyDigests) *SignatureVerification {
+	failed := func(format string, args ...any) *SignatureVerification {
+		return &SignatureVerification{Error: fmt.Sprintf(format, args...)}
+	}
+	inputs, err := parseSFDictionary(strings.Join(resp.Header.Values("Signature-Input"), ", "))
+	if err != nil {
+		return failed("invalid Signature-Input header: %s", err)
+	}
+	signatures, err := parseSFDictionary(strings.Join(resp.Header.Values("Signature"), ", "))
+	if err != nil {
+		return failed("invalid Signature header: %s", err)
+	}
+	if len(inputs) == 0 {
+		return failed("the response is not signed")
+	}
+
+	message := &signedMessage{request: resp.Request, status: resp.StatusCode, header: resp.Header}
+	var result *SignatureVerification
+	var failures []string
+	for _, input := range inputs {
+		verification, err := verifySignature(message, key, input, signatures, maxAge, digests)
+		if err == nil {
+			return verification
+		}
+		// The first signature made with the key tells more than the skipped ones
+		if result == nil || (result.Label == "" && verification.Label != "") {
+			result = verification
+		}
+		failures = append(failures, fmt.Sprintf("%s: %s", input.key, err))
+	}
+	result.Error = strings.Join(failures, "; ")
+	return result
+}
+
+// verifySignature verifies the signature named by a member of the Signature-Input header
+func verifySignature(message *signedMessage, key *SignatureKey, input sfMember, signatures []sfMember, maxAge time.Duration, digests *bodyDigests) (*SignatureVerification, error) {
+	verification := &SignatureVerification{}
+	if !input.isList {
+		return verification, fmt.Errorf("signature input is not a list of components")
+	}
+	var created, expires int64
+	for _, param := range input.params {
+		switch value := param.value.(type) {
+		case string:
+			switch param.key {
+			case "keyid":
+				verification.KeyID = value
+			case "alg":
+				verification.Algorithm = value
+			}
+		case int64:
+			switch param.key {
+			case "created":
+				created = value
+			case "expires":
+				expires = value
+			}
+		}
+	}
+	if key.ID != "" && verification.KeyID != "" && verification.KeyID != key.ID {
+		return verification, fmt.Errorf("signed with key %s", verification.KeyID)
+	}
+	if verification.Algorithm != "" && verification.Algorithm != key.Algorithm {
+		return verification, fmt.Errorf("signed with %s, the key is for %s", verification.Algorithm, key.Algorithm)
+	}
+	verification.Label = input.key
+	verification.Algorithm = key.Algorithm
+	for _, component := range input.list {
+		verification.Components = append(verification.Components, serializeItem(component))
+	}
+
+	// A signature over its parameters only says nothing about the response
+	if len(input.list) == 0 {
+		return verification, fmt.Errorf("signature covers no component")
+	}
+	coversDigest := false
+	for _, component := range input.list {
+		if component.value == "content-digest" && len(component.params) == 0 {
+			coversDigest = true
+		}
+	}
+	if digests.size > 0 && !coversDigest {
+		return verification, fmt.Errorf("signature does not cover the body, content-digest is not covered")
+	}
+
+	now := time.Now()
+	switch {
+	case expires != 0 && now.Unix() > expires:
+		return verification, fmt.Errorf("signature expired at %s", time.Unix(expires, 0).Format(time.RFC3339))
+	case created > now.Add(signatureClockSkew).Unix():
+		return verification, fmt.Errorf("signature created in the future")
+	case maxAge > 0 && created == 0:
+		return verification, fmt.Errorf("signature has no created time")
+	case maxAge > 0 && created < now.Add(-maxAge).Unix():
+		return verification, fmt.Errorf("signature created at %s, more than %s ago", time.Unix(created, 0).Format(time.RFC3339), maxAge)
+	}
+
+	var signature []byte
+	for _, member := range signatures {
+		if member.key == input.key && !member.isList {
+			signature, _ = member.item.value.([]byte)
+		}
+	}
+	if signature == nil {
+		return verification, fmt.Errorf("no matching Signature header")
+	}
+
+	base, err := signatureBase(message, input.list, serializeInnerList(input.list, input.params))
+	if err != nil {
+		return verification, err
+	}
+	if err := key.verify(base, signature); err != nil {
+		return verification, err
+	}
+	if coversDigest {
+		if err := checkContentDigest(message.header.Values("Content-Digest"), digests); err != nil {
+			return verification, err
+		}
+	}
+	verification.Verified = true
+	return verification, nil
+}
+
+// checkContentDigest compares a Content-Digest header (RFC 9530) with the digests of the body
+// received, at least one supported algorithm must be present and every one must match
+func checkContentDigest(values []string, digests *bodyDigests) error {
+	members, err := parseSFDictionary(strings.Join(values, ", "))
+	if err != nil {
+		return fmt.Errorf("invalid Content-Digest header: %w", err)
+	}
+	checked := false
+	for _, member := range members {
+		var sum []byte
+		switch member.key {
+		case "sha-256":
+			sum = digests.sha256.Sum(nil)
+		case "sha-512":
+			sum = digests.sha512.Sum(nil)
+		default:
+			continue
+		}
+		value, ok := member.item.value.([]byte)
+		if member.isList || !ok || !hmac.Equal(value, sum) {
+			return fmt.Errorf("Content-Digest %s does not match the body", member.key)
+		}
+		checked = true
+	}
+	if !checked {
+		return fmt.Errorf("no sha-256 or sha-512 Content-Digest")
+	}
+	return nil
+}
+
+// signedMessage is a request or a response whose components are signed
+type signedMessage struct {
+	request *http.Request // The request, or the request of the response
+	status  int           // Status of a response, 0 for a request
+	header  http.Header
+}
+
+// signatureBase builds the signature base of the covered components (RFC 9421 section 2.5)
+func signatureBase(message *signedMessage, components []sfItem, signatureParams string) ([]byte, error) {
+	var base strings.Builder
+	seen := make(map[string]bool)
+	for _, component := range components {
+		id := serializeItem(component)
+		if seen[id] {
+			return nil, fmt.Errorf("component %s is covered twice", id)
+		}
+		seen[id] = true
+		value, err := componentValue(message, component)
+		if err != nil {
+			return nil, err
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("component %s holds a line break", id)
+		}
+		base.WriteString(id + ": " + value + "\n")
+	}
+	base.WriteString(`"@signature-params": ` + signatureParams)
+	return []byte(base.String()), nil
+}
+
+// componentValue returns the value of a component in the message, the req parameter
+// of a response component selects the request it answers
+func componentValue(message *signedMessage, component sfItem) (string, error) {
+	name, _ := component.value.(string)
+	queryParam := ""
+	for _, param := range component.params {
+		switch {
+		case param.key == "req" && param.value == true:
+			if message.status == 0 {
+				return "", fmt.Errorf("component %s: req only applies to responses", serializeItem(component))
+			}
+			message = &signedMessage{request: message.request, header: message.request.Header}
+		case param.key == "name" && name == "@query-param":
+			queryParam, _ = param.value.(string)
+		default:
+			return "", fmt.Errorf("component %s: unsupported parameter %s", serializeItem(component), param.key)
+		}
+	}
+
+	req := message.request
+	if !strings.HasPrefix(name, "@") {
+		values := message.header.Values(name)
+		if len(values) == 0 {
+			return "", fmt.Errorf("component %s is not in the message", name)
+		}
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.TrimSpace(value)
+		}
+		return strings.Join(trimmed, ", "), nil
+	}
+	if name == "@status" {
+		if message.status == 0 {
+			return "", fmt.Errorf("component @status only applies to responses")
+		}
+		return strconv.Itoa(message.status), nil
+	}
+	if req == nil {
+		return "", fmt.Errorf("component %s needs the request", name)
+	}
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	switch name {
+	case "@method":
+		return req.Method, nil
+	case "@target-uri":
+		return strings.ToLower(req.URL.Scheme) + "://" + signatureAuthority(req) + req.URL.RequestURI(), nil
+	case "@authority":
+		return signatureAuthority(req), nil
+	case "@scheme":
+		return strings.ToLower(req.URL.Scheme), nil
+	case "@request-target":
+		return req.URL.RequestURI(), nil
+	case "@path":
+		return path, nil
+	case "@query":
+		return "?" + req.URL.RawQuery, nil
+	case "@query-param":
+		if queryParam == "" {
+			return "", fmt.Errorf("component @query-param needs a name parameter")
+		}
+		values := req.URL.Query()[queryParam]
+		if len(values) != 1 {
+			return "", fmt.Errorf("component @query-param;name=%q needs the parameter exactly once in the query", queryParam)
+		}
+		return strings.ReplaceAll(url.QueryEscape(values[0]), "+", "%20"), nil
+	}
+	return "", fmt.Errorf("unsupported derived component %s", name)
+}
+
+// signatureAuthority returns the host of the request in lowercase, without its default port
+func signatureAuthority(req *http.Request) string {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	host = strings.ToLower(host)
+	switch scheme := strings.ToLower(req.URL.Scheme); {
+	case scheme == "http" && strings.HasSuffix(host, ":80"):
+		return strings.TrimSuffix(host, ":80")
+	case scheme == "https" && strings.HasSuffix(host, ":443"):
+		return strings.TrimSuffix(host, ":443")
+	}
+	return host
+}
+
+// parseComponentSpec parses a component given as name;param=value, such as
+// @query-param;name=id or content-type
+func parseComponentSpec(spec string) (sfItem, error) {
+	name, params, _ := strings.Cut(strings.TrimSpace(spec), ";")
+	component := sfItem{value: strings.ToLower(name)}
+	if name == "" {
+		return component, fmt.Errorf("empty signature component")
+	}
+	if params != "" {
+		parser := &sfParser{input: ";" + params}
+		var err error
+		if component.params, err = parser.parseParams(); err != nil || parser.pos != len(parser.input) {
+			return component, fmt.Errorf("invalid signature component %s", spec)
+		}
+		// Parameter values given bare are strings, like the query parameter names
+		for i, param := range component.params {
+			if token, ok := param.value.(sfToken); ok {
+				component.params[i].value = string(token)
+			}
+		}
+	}
+	return component, nil
+}
+
+// hashBody writes a copy of the request body to h. A body that cannot be read twice,
+// such as the standard input, cannot be hashed before it is sent.
+func hashBody(req *http.Request, h io.Writer, purpose string) error {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	if req.GetBody == nil {
+		return fmt.Errorf("the %s of a body read from the standard input cannot be computed, give it as @file", purpose)
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return fmt.Errorf("failed to read body for the %s: %w", purpose, err)
+	}
+	defer body.Close()
+	if _, err := io.Copy(h, body); err != nil {
+		return fmt.Errorf("failed to read body for the %s: %w", purpose, err)
+	}
+	return nil
+}
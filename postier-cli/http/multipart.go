@@ -0,0 +1,201 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MultipartField is one part of a multipart/form-data body
+type MultipartField struct {
+	Name        string
+	Value       string // Text value, used when File is empty
+	File        string // Path of the file sent as the part content, - for the standard input
+	ContentType string // Content type of a file part, guessed from the extension when empty
+	Filename    string // File name sent for a file part, the base name of File when empty
+}
+
+// ParseMultipartField parses a field given as name=value or name=@file[;type=mime][;filename=name].
+// A text value is taken as is, semicolons included.
+func ParseMultipartField(spec string) (MultipartField, error) {
+	name, value, found := strings.Cut(spec, "=")
+	if !found || name == "" {
+		return MultipartField{}, fmt.Errorf("invalid field %q, expected name=value or name=@file", spec)
+	}
+
+	field := MultipartField{Name: name}
+	if !strings.HasPrefix(value, "@") {
+		field.Value = value
+		return field, nil
+	}
+
+	params := strings.Split(value[1:], ";")
+	field.File = params[0]
+	if field.File == "" {
+		return MultipartField{}, fmt.Errorf("invalid field %q, missing file name after @", spec)
+	}
+	for _, param := range params[1:] {
+		key, paramValue, _ := strings.Cut(strings.TrimSpace(param), "=")
+		switch key {
+		case "type":
+			field.ContentType = paramValue
+		case "filename":
+			field.Filename = paramValue
+		default:
+			return MultipartField{}, fmt.Errorf("invalid field %q, unknown parameter %q", spec, key)
+		}
+	}
+	return field, nil
+}
+
+// multipartBody streams a multipart body, file parts are read from disk as the body is sent
+type multipartBody struct {
+	io.Reader
+	files    []*os.File
+	size     int64  // Total size, -1 when a part is read from the standard input
+	boundary string // Boundary between the parts
+}
+
+func (b *multipartBody) Close() error {
+	for _, file := range b.files {
+		file.Close()
+	}
+	return nil
+}
+
+// quoteEscaper escapes the values of the Content-Disposition header like mime/multipart
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// ParseMultipart builds a multipart/form-data body from field specs, see ParseMultipartField.
+// The part headers are prepared up front and the file contents are streamed when the body is read.
+func ParseMultipart(fields []string) (io.Reader, string, error) {
+	return parseMultipart(fields, "")
+}
+
+// parseMultipart builds a multipart body separated by boundary, a random one when it is empty
+func parseMultipart(fields []string, boundary string) (io.Reader, string, error) {
+	if len(fields) == 0 {
+		return nil, "", nil
+	}
+
+	// The multipart writer renders boundaries and part headers into buf, each rendered
+	// segment is followed by the reader of the part content
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if boundary != "" {
+		if err := writer.SetBoundary(boundary); err != nil {
+			return nil, "", fmt.Errorf("failed to build multipart body: %w", err)
+		}
+	}
+	body := &multipartBody{boundary: writer.Boundary()}
+	var readers []io.Reader
+	unknownSize := false
+	flush := func() {
+		segment := bytes.Clone(buf.Bytes())
+		readers = append(readers, bytes.NewReader(segment))
+		body.size += int64(len(segment))
+		buf.Reset()
+	}
+
+	for _, spec := range fields {
+		field, err := ParseMultipartField(spec)
+		if err != nil {
+			body.Close()
+			return nil, "", err
+		}
+
+		if field.File == "" {
+			part, err := writer.CreateFormField(field.Name)
+			if err == nil {
+				_, err = io.WriteString(part, field.Value)
+			}
+			if err != nil {
+				body.Close()
+				return nil, "", fmt.Errorf("failed to build field %s: %w", field.Name, err)
+			}
+			continue
+		}
+
+		content, size, err := openMultipartFile(field.File)
+		if err != nil {
+			body.Close()
+			return nil, "", err
+		}
+		if file, ok := content.(*os.File); ok && file != os.Stdin {
+			body.files = append(body.files, file)
+		}
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			quoteEscaper.Replace(field.Name), quoteEscaper.Replace(multipartFilename(field))))
+		header.Set("Content-Type", multipartContentType(field))
+		if _, err := writer.CreatePart(header); err != nil {
+			body.Close()
+			return nil, "", fmt.Errorf("failed to build field %s: %w", field.Name, err)
+		}
+		flush()
+
+		readers = append(readers, content)
+		if size < 0 {
+			unknownSize = true
+		}
+		body.size += size
+	}
+
+	if err := writer.Close(); err != nil {
+		body.Close()
+		return nil, "", fmt.Errorf("failed to build multipart body: %w", err)
+	}
+	flush()
+	if unknownSize {
+		body.size = -1
+	}
+
+	body.Reader = io.MultiReader(readers...)
+	return body, writer.FormDataContentType(), nil
+}
+
+// openMultipartFile opens the content of a file part and returns its size, -1 for the standard input
+func openMultipartFile(path string) (io.Reader, int64, error) {
+	if path == "-" {
+		return os.Stdin, -1, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read field file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		file.Close()
+		return nil, 0, fmt.Errorf("failed to read field file %s: not a regular file", path)
+	}
+	return file, info.Size(), nil
+}
+
+// multipartFilename returns the file name sent for a file part
+func multipartFilename(field MultipartField) string {
+	switch {
+	case field.Filename != "":
+		return field.Filename
+	case field.File == "-":
+		return "stdin"
+	}
+	return filepath.Base(field.File)
+}
+
+// multipartContentType returns the content type of a file part
+func multipartContentType(field MultipartField) string {
+	if field.ContentType != "" {
+		return field.ContentType
+	}
+	if contentType := mime.TypeByExtension(filepath.Ext(field.File)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
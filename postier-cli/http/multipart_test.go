@@ -0,0 +1,178 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseMultipartField(t *testing.T) {
+	for _, test := range []struct {
+		spec    string
+		want    MultipartField
+		wantErr string
+	}{
+		{"name=Ada", MultipartField{Name: "name", Value: "Ada"}, ""},
+		{"note=a=b;c", MultipartField{Name: "note", Value: "a=b;c"}, ""},
+		{"empty=", MultipartField{Name: "empty"}, ""},
+		{"avatar=@me.png", MultipartField{Name: "avatar", File: "me.png"}, ""},
+		{"doc=@notes.txt;type=text/markdown; filename=readme.md", MultipartField{Name: "doc", File: "notes.txt", ContentType: "text/markdown", Filename: "readme.md"}, ""},
+		{"data=@-", MultipartField{Name: "data", File: "-"}, ""},
+		{"name", MultipartField{}, "expected name=value or name=@file"},
+		{"=value", MultipartField{}, "expected name=value or name=@file"},
+		{"file=@", MultipartField{}, "missing file name after @"},
+		{"file=@a.txt;size=3", MultipartField{}, `unknown parameter "size"`},
+	} {
+		t.Run(test.spec, func(t *testing.T) {
+			got, err := ParseMultipartField(test.spec)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("got %v, want an error with %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+// multipartServer parses the multipart body of /upload and answers with its parts,
+// /redirect sends the body again to /upload. Each request records its boundary.
+func multipartServer(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var boundaries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		boundaries = append(boundaries, params["boundary"])
+		mu.Unlock()
+
+		if r.URL.Path == "/redirect" {
+			io.Copy(io.Discard, r.Body)
+			http.Redirect(w, r, "/upload", http.StatusTemporaryRedirect)
+			return
+		}
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, "length %d\n", r.ContentLength)
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			content, _ := io.ReadAll(part)
+			fmt.Fprintf(w, "%s %q %s %s\n", part.FormName(), part.FileName(), part.Header.Get("Content-Type"), content)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), boundaries...)
+	}
+}
+
+func TestMultipart(t *testing.T) {
+	dir := t.TempDir()
+	image := filepath.Join(dir, "me.png")
+	notes := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(image, []byte("PNG"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(notes, []byte("# notes"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fields := []string{
+		"name=Ada",
+		"avatar=@" + image,
+		"doc=@" + notes + ";type=text/markdown;filename=readme.md",
+	}
+
+	for _, path := range []string{"/upload", "/redirect"} {
+		t.Run(path, func(t *testing.T) {
+			server, boundaries := multipartServer(t)
+			client, err := NewClient()
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(&Request{Method: "POST", URL: server.URL + path, BodyType: "multipart", Fields: fields})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("got %d: %s", resp.StatusCode, resp.Body)
+			}
+
+			// The size is known up front, the files are streamed with their name and type
+			lines := strings.Split(strings.TrimSpace(resp.Body), "\n")
+			want := []string{
+				`name ""  Ada`,
+				`avatar "me.png" image/png PNG`,
+				`doc "readme.md" text/markdown # notes`,
+			}
+			if len(lines) != 4 || lines[0] == "length -1" || strings.Join(lines[1:], "\n") != strings.Join(want, "\n") {
+				t.Errorf("server got:\n%s\nwant a known length and:\n%s", resp.Body, strings.Join(want, "\n"))
+			}
+
+			// A body sent again keeps the boundary of its Content-Type
+			got := boundaries()
+			if len(got) == 0 || got[0] == "" {
+				t.Fatalf("got boundaries %q", got)
+			}
+			for _, boundary := range got[1:] {
+				if boundary != got[0] {
+					t.Errorf("got boundaries %q, want the same on every hop", got)
+				}
+			}
+		})
+	}
+}
+
+func TestMultipartInvalid(t *testing.T) {
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name    string
+		req     Request
+		wantErr string
+	}{
+		{"with a body", Request{BodyType: "multipart", Fields: []string{"a=b"}, Body: "raw"}, "it does not take a body"},
+		{"with form fields", Request{BodyType: "multipart", Fields: []string{"a=b"}, Form: []string{"c=d"}}, "multipart fields and form fields cannot be combined"},
+		{"missing file", Request{BodyType: "multipart", Fields: []string{"a=@" + filepath.Join(t.TempDir(), "missing")}}, "failed to read field file"},
+		{"directory", Request{BodyType: "multipart", Fields: []string{"a=@" + t.TempDir()}}, "not a regular file"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.req.Method = "POST"
+			test.req.URL = "http://127.0.0.1:1"
+			if _, err := client.Do(&test.req); err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("got %v, want an error with %q", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ProgressObserver receives phase updates while a request is in flight.
+// ui.ProgressDisplay implements it for the terminal.
+type ProgressObserver interface {
+	Start()
+	Update(phase, state string, duration time.Duration)
+	Complete()
+}
+
+// TransferObserver is implemented by progress observers that also follow the bytes
+// of a body transfer. Direction is "download", total is -1 when the size is unknown.
+type TransferObserver interface {
+	TransferProgress(direction string, done, total int64)
+}
+
+// noopProgress is the default observer, it ignores every update
+type noopProgress struct{}
+
+func (noopProgress) Start()                               {}
+func (noopProgress) Update(string, string, time.Duration) {}
+func (noopProgress) Complete()                            {}
+
+// Client sends requests described by Request and reuses its connections across calls
+type Client struct {
+	ctx         context.Context
+	transport   http.RoundTripper
+	timeout     time.Duration
+	progress    ProgressObserver
+	insecure    bool
+	caCert      string
+	caPath      string
+	clientCert  ClientCert
+	httpVersion string
+	redirects   RedirectPolicy
+	retry       RetryPolicy
+	proxyURL    string
+	noProxy     *string
+	unixSocket  string
+	ipVersion   string
+	resolve     []string
+	connectTo   []string
+	dnsServers  []string
+	overrides   *hostOverrides
+	resolver    *net.Resolver
+	compressed  bool
+	cookieJar   *CookieJar
+
+	connectTimeout        time.Duration
+	tlsTimeout            time.Duration
+	responseHeaderTimeout time.Duration
+
+	httpClient *http.Client
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithTransport sets the round tripper used to send requests
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.transport = transport
+	}
+}
+
+// WithTimeout sets the overall time limit for a request, body transfer included, zero means no limit
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithProgress sets the observer notified of each request phase
+func WithProgress(progress ProgressObserver) Option {
+	return func(c *Client) {
+		if progress == nil {
+			progress = noopProgress{}
+		}
+		c.progress = progress
+	}
+}
+
+// WithContext sets the base context used by Do
+func WithContext(ctx context.Context) Option {
+	return func(c *Client) {
+		c.ctx = ctx
+	}
+}
+
+// NewClient creates a client configured with the given options
+func NewClient(opts ...Option) (*Client, error) {
+	c := &Client{
+		ctx:       context.Background(),
+		progress:  noopProgress{},
+		redirects: RedirectPolicy{Follow: true, MaxRedirects: DefaultMaxRedirects},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.transport == nil {
+		transport, err := c.newTransport()
+		if err != nil {
+			return nil, err
+		}
+		c.transport = transport
+	}
+
+	c.httpClient = &http.Client{
+		Transport:     &authTransport{next: c.transport, keepAuth: c.redirects.KeepAuth},
+		CheckRedirect: c.checkRedirect,
+	}
+	if c.cookieJar != nil {
+		c.httpClient.Jar = c.cookieJar
+	}
+
+	return c, nil
+}
+
+// Close closes the idle connections of the client, with the UDP sockets of its HTTP/3 connections
+func (c *Client) Close() {
+	c.httpClient.CloseIdleConnections()
+}
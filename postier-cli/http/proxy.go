@@ -0,0 +1,141 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// WithProxy sends requests through a proxy: http, https, socks5 or socks5h URL,
+// credentials may be given in the URL. Without it the HTTP_PROXY, HTTPS_PROXY
+// and NO_PROXY environment variables are used.
+func WithProxy(proxyURL string) Option {
+	return func(c *Client) {
+		c.proxyURL = proxyURL
+	}
+}
+
+// WithNoProxy sets the comma separated hosts that bypass the proxy, replacing NO_PROXY
+func WithNoProxy(noProxy string) Option {
+	return func(c *Client) {
+		c.noProxy = &noProxy
+	}
+}
+
+// newProxyFunc builds the proxy selection from the client options and the environment
+func (c *Client) newProxyFunc() (func(*url.URL) (*url.URL, error), error) {
+	config := httpproxy.FromEnvironment()
+	if c.proxyURL != "" {
+		proxyURL, err := url.Parse(c.proxyURL)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL: %s", c.proxyURL)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme: %s", proxyURL.Scheme)
+		}
+		config.HTTPProxy, config.HTTPSProxy = c.proxyURL, c.proxyURL
+	}
+	if c.noProxy != nil {
+		config.NoProxy = *c.noProxy
+	}
+
+	if config.HTTPProxy == "" && config.HTTPSProxy == "" {
+		return nil, nil
+	}
+	return config.ProxyFunc(), nil
+}
+
+// transportProxy returns the Proxy function of the transport. HTTP proxies are handled by
+// net/http, SOCKS proxies are recorded in the request trace and dialed by dialContext so
+// the proxy handshake can be timed.
+func (c *Client) transportProxy(proxyFunc func(*url.URL) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	if proxyFunc == nil {
+		return nil
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		trace := contextRequestTrace(req.Context())
+		proxyURL, err := proxyFunc(req.URL)
+		if err != nil || proxyURL == nil {
+			// A redirect to a host of NO_PROXY must not keep the proxy of the previous hop
+			trace.useProxy(nil, false)
+			return nil, err
+		}
+
+		if isSOCKS(proxyURL) {
+			trace.useProxy(proxyURL, true)
+			return nil, nil
+		}
+		// Plain HTTP requests are forwarded by the proxy, HTTPS needs a CONNECT tunnel
+		trace.useProxy(proxyURL, req.URL.Scheme == "https")
+		return proxyURL, nil
+	}
+}
+
+// onProxyConnectResponse ends the proxy connect phase once the CONNECT tunnel is established
+func onProxyConnectResponse(ctx context.Context, _ *url.URL, _ *http.Request, _ *http.Response) error {
+	contextRequestTrace(ctx).proxyConnectDone()
+	return nil
+}
+
+// dialSOCKS opens a connection to addr through a SOCKS5 proxy
+func (c *Client) dialSOCKS(ctx context.Context, proxyURL *url.URL, network, addr string) (net.Conn, error) {
+	// The connect limit covers the proxy handshake as well
+	if c.connectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.connectTimeout)
+		defer cancel()
+	}
+
+	var auth *proxy.Auth
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+	}
+
+	// socks5 resolves the host locally, socks5h lets the proxy resolve it
+	if proxyURL.Scheme == "socks5" {
+		resolved, err := c.resolveAddr(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		addr = resolved
+	} else {
+		addr = c.applyConnectTo(addr)
+	}
+
+	dialer, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, directDialer(c.dialProxy))
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dialer.(proxy.ContextDialer).DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("SOCKS proxy %s: %w", proxyURL.Host, err)
+	}
+	contextRequestTrace(ctx).proxyConnectDone()
+	return conn, nil
+}
+
+// isSOCKS reports whether the proxy URL designates a SOCKS proxy
+func isSOCKS(proxyURL *url.URL) bool {
+	return strings.HasPrefix(proxyURL.Scheme, "socks")
+}
+
+// directDialer adapts a dial function to the dialer interfaces of x/net/proxy
+type directDialer func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func (d directDialer) Dial(network, addr string) (net.Conn, error) {
+	return d(context.Background(), network, addr)
+}
+
+func (d directDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return d(ctx, network, addr)
+}
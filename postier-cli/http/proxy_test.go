@@ -0,0 +1,177 @@
+package http
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// socksServer is a SOCKS5 proxy without authentication, it connects every
+// host name to target and counts the tunnels it opened
+type socksServer struct {
+	listener net.Listener
+	target   string
+
+	mu      sync.Mutex
+	tunnels []string // Address asked for each tunnel
+}
+
+func startSOCKSServer(t *testing.T, target string) *socksServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &socksServer{listener: listener, target: target}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *socksServer) serve(conn net.Conn) {
+	defer conn.Close()
+	// Greeting: version, methods, answered with "no authentication"
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+		return
+	}
+	conn.Write([]byte{5, 0})
+
+	// Request: version, command, reserved, address type, address, port
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return
+	}
+	var host string
+	switch request[3] {
+	case 1:
+		ip := make([]byte, 4)
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case 3:
+		size := make([]byte, 1)
+		io.ReadFull(conn, size)
+		name := make([]byte, size[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	default:
+		return
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+	s.mu.Lock()
+	s.tunnels = append(s.tunnels, addr)
+	s.mu.Unlock()
+
+	target, err := net.Dial("tcp", s.target)
+	if err != nil {
+		conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
+
+func (s *socksServer) opened() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.tunnels...)
+}
+
+func TestSOCKSProxyRedirectToNoProxyHost(t *testing.T) {
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("direct"))
+	}))
+	defer direct.Close()
+	proxied := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, direct.URL+"/landing", http.StatusFound)
+	}))
+	defer proxied.Close()
+	proxy := startSOCKSServer(t, proxied.Listener.Addr().String())
+
+	client, err := NewClient(WithProxy("socks5h://"+proxy.listener.Addr().String()), WithNoProxy("127.0.0.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(&Request{Method: "GET", URL: "http://api.internal/start"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Body != "direct" || len(resp.Redirects) != 1 {
+		t.Fatalf("got %q after %d redirects, want the direct server after 1", resp.Body, len(resp.Redirects))
+	}
+	// Only the first hop goes through the proxy, the redirect target is dialed directly
+	if tunnels := proxy.opened(); len(tunnels) != 1 || tunnels[0] != "api.internal:80" {
+		t.Errorf("got proxy tunnels %v, want one to api.internal:80", tunnels)
+	}
+	if resp.Redirects[0].Timings.ProxyConnect <= 0 {
+		t.Errorf("proxy connect of the first hop not recorded")
+	}
+	if resp.Timings.ProxyConnect != 0 {
+		t.Errorf("got proxy connect %s on the direct hop", resp.Timings.ProxyConnect)
+	}
+	if resp.Connection == nil || resp.Connection.RemoteAddr != direct.Listener.Addr().String() {
+		t.Errorf("got connection %+v, want the direct server", resp.Connection)
+	}
+}
+
+func TestHTTPProxy(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string // Request URI and proxy credentials of each forwarded request
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.RequestURI+" "+r.Header.Get("Proxy-Authorization"))
+		mu.Unlock()
+		w.Write([]byte("from proxy"))
+	}))
+	defer proxy.Close()
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("direct"))
+	}))
+	defer direct.Close()
+
+	proxyURL := "http://user:secret@" + proxy.Listener.Addr().String()
+	client, err := NewClient(WithProxy(proxyURL), WithNoProxy("127.0.0.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(&Request{Method: "GET", URL: "http://api.internal/users"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Body != "from proxy" {
+		t.Errorf("got %q, want the proxy answer", resp.Body)
+	}
+	// NO_PROXY hosts are reached directly
+	if resp, err = client.Do(&Request{Method: "GET", URL: direct.URL}); err != nil || resp.Body != "direct" {
+		t.Errorf("got %v %v, want the direct server", resp, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != 1 || seen[0] != "http://api.internal/users Basic dXNlcjpzZWNyZXQ=" {
+		t.Errorf("proxy got %v, want one absolute request with the credentials", seen)
+	}
+}
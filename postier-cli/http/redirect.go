@@ -0,0 +1,88 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultMaxRedirects is the number of redirects followed when no limit is set
+const DefaultMaxRedirects = 10
+
+// RedirectPolicy controls how redirect responses are handled
+type RedirectPolicy struct {
+	Follow       bool // Follow redirects, otherwise the redirect response itself is returned
+	MaxRedirects int  // Maximum number of redirects to follow, zero follows none and a negative value means DefaultMaxRedirects
+	KeepAuth     bool // Keep Authorization and Cookie headers when redirected to another host
+}
+
+// RedirectHop represents a redirect response followed by the client
+type RedirectHop struct {
+	StatusCode int          `json:"status_code"` // Status code of the redirect response
+	URL        string       `json:"url"`         // URL that answered with the redirect
+	Location   string       `json:"location"`    // Target of the redirect
+	Timings    *HTTPTimings `json:"timings"`     // Timings of this hop only
+}
+
+// sensitiveHeaders are dropped by net/http on cross-host redirects
+var sensitiveHeaders = []string{"Authorization", "Www-Authenticate", "Cookie", "Cookie2", "Proxy-Authorization"}
+
+// WithRedirectPolicy sets how redirect responses are handled
+func WithRedirectPolicy(policy RedirectPolicy) Option {
+	return func(c *Client) {
+		c.redirects = policy
+	}
+}
+
+// checkRedirect applies the redirect policy and records the hop in the request trace
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if !c.redirects.Follow {
+		return http.ErrUseLastResponse
+	}
+
+	maxRedirects := c.redirects.MaxRedirects
+	if maxRedirects < 0 {
+		maxRedirects = DefaultMaxRedirects
+	}
+	if len(via) > maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+
+	if trace := contextRequestTrace(req.Context()); trace != nil && req.Response != nil {
+		trace.redirected(req.Response.StatusCode, via[len(via)-1].URL.String(), req.URL.String())
+	}
+
+	// net/http only copies the sensitive headers when the target stays on the same domain
+	if c.redirects.KeepAuth {
+		for _, key := range sensitiveHeaders {
+			if values, ok := via[0].Header[key]; ok {
+				req.Header[key] = values
+			}
+		}
+	}
+
+	return nil
+}
+
+// redirected closes the current hop and resets the timings for the next one
+func (t *requestTrace) redirected(statusCode int, from, location string) {
+	t.mu.Lock()
+	hopTimings := *t.timings
+	hopTimings.Total = time.Since(t.hopStart)
+
+	t.redirects = append(t.redirects, RedirectHop{
+		StatusCode: statusCode,
+		URL:        from,
+		Location:   location,
+		Timings:    &hopTimings,
+	})
+
+	*t.timings = HTTPTimings{}
+	t.dnsStart, t.connectStart, t.tlsStart, t.quicStart, t.proxyStart, t.writeStart, t.firstByteStart = time.Time{}, time.Time{}, time.Time{}, time.Time{}, time.Time{}, time.Time{}, time.Time{}
+	t.hopStart = time.Now()
+	t.mu.Unlock()
+	// The proxy of the next hop is selected again when it is sent
+	t.proxy, t.proxyTunnel = nil, false
+	t.resetConn()
+	t.setPhase(PhaseConnect)
+}
@@ -0,0 +1,44 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMaxRedirects(t *testing.T) {
+	// /4 redirects to /3 and so on down to /0, which answers 200
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		if n > 0 {
+			http.Redirect(w, r, "/"+strconv.Itoa(n-1), http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	for _, test := range []struct {
+		max     int
+		wantErr bool
+	}{
+		{0, true},
+		{3, true},
+		{4, false},
+		{-1, false}, // DefaultMaxRedirects
+	} {
+		client, err := NewClient(WithRedirectPolicy(RedirectPolicy{Follow: true, MaxRedirects: test.max}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(&Request{Method: "GET", URL: server.URL + "/4"})
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("max %d: followed %d redirects, want an error", test.max, len(resp.Redirects))
+		case !test.wantErr && err != nil:
+			t.Errorf("max %d: %v", test.max, err)
+		case !test.wantErr && len(resp.Redirects) != 4:
+			t.Errorf("max %d: followed %d redirects, want 4", test.max, len(resp.Redirects))
+		}
+	}
+}
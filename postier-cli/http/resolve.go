@@ -0,0 +1,272 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// IP versions accepted by WithIPVersion
+const (
+	IPVersionAny = ""  // IPv4 or IPv6, whichever the name resolves to
+	IPVersion4   = "4" // IPv4 only
+	IPVersion6   = "6" // IPv6 only
+)
+
+// WithIPVersion restricts the addresses used to reach the server to IPv4 or IPv6
+func WithIPVersion(version string) Option {
+	return func(c *Client) {
+		c.ipVersion = version
+	}
+}
+
+// WithResolve pins host names to addresses, like curl --resolve. Each entry is
+// host:port:addr[,addr...], IPv6 addresses are given in brackets.
+func WithResolve(entries ...string) Option {
+	return func(c *Client) {
+		c.resolve = append(c.resolve, entries...)
+	}
+}
+
+// WithConnectTo sends the connections for a host and port to another one, like curl
+// --connect-to. Each entry is host1:port1:host2:port2, an empty host1 or port1 matches
+// any value and an empty host2 or port2 keeps the original one.
+func WithConnectTo(entries ...string) Option {
+	return func(c *Client) {
+		c.connectTo = append(c.connectTo, entries...)
+	}
+}
+
+// WithDNSServers resolves host names with these DNS servers instead of the system
+// configuration. Each server is ip or ip:port, the port defaults to 53.
+func WithDNSServers(servers ...string) Option {
+	return func(c *Client) {
+		c.dnsServers = append(c.dnsServers, servers...)
+	}
+}
+
+// hostOverrides holds the parsed resolve and connect-to rules
+type hostOverrides struct {
+	resolve   map[string][]string // host:port to the addresses to dial instead
+	connectTo []connectToRule
+}
+
+// connectToRule redirects the connections matching host and port, empty fields are wildcards
+type connectToRule struct {
+	host, port     string
+	toHost, toPort string
+}
+
+// initResolver parses the host overrides and builds the DNS resolver from the client options
+func (c *Client) initResolver() error {
+	switch c.ipVersion {
+	case IPVersionAny, IPVersion4, IPVersion6:
+	default:
+		return fmt.Errorf("unsupported IP version: %s", c.ipVersion)
+	}
+
+	overrides := &hostOverrides{resolve: make(map[string][]string)}
+	for _, entry := range c.resolve {
+		fields := splitHostFields(entry, 3)
+		if len(fields) != 3 || fields[0] == "" || !validPort(fields[1]) {
+			return fmt.Errorf("invalid resolve entry %q, expected host:port:addr", entry)
+		}
+		key := overrideKey(fields[0], fields[1])
+		for _, addr := range strings.Split(fields[2], ",") {
+			ip := net.ParseIP(strings.Trim(strings.TrimSpace(addr), "[]"))
+			if ip == nil {
+				return fmt.Errorf("invalid address %q in resolve entry %q", addr, entry)
+			}
+			overrides.resolve[key] = append(overrides.resolve[key], net.JoinHostPort(ip.String(), fields[1]))
+		}
+	}
+	for _, entry := range c.connectTo {
+		fields := splitHostFields(entry, 4)
+		if len(fields) != 4 || (fields[1] != "" && !validPort(fields[1])) || (fields[3] != "" && !validPort(fields[3])) {
+			return fmt.Errorf("invalid connect-to entry %q, expected host1:port1:host2:port2", entry)
+		}
+		overrides.connectTo = append(overrides.connectTo, connectToRule{
+			host:   strings.ToLower(strings.Trim(fields[0], "[]")),
+			port:   fields[1],
+			toHost: strings.Trim(fields[2], "[]"),
+			toPort: fields[3],
+		})
+	}
+	c.overrides = overrides
+
+	if len(c.dnsServers) > 0 {
+		servers := make([]string, 0, len(c.dnsServers))
+		for _, server := range c.dnsServers {
+			server = strings.TrimSpace(server)
+			if ip := net.ParseIP(strings.Trim(server, "[]")); ip != nil {
+				server = net.JoinHostPort(ip.String(), "53")
+			} else if host, port, err := net.SplitHostPort(server); err != nil || net.ParseIP(host) == nil || !validPort(port) {
+				return fmt.Errorf("invalid DNS server %q, expected ip or ip:port", server)
+			}
+			servers = append(servers, server)
+		}
+		c.resolver = newDNSResolver(servers)
+	}
+
+	return nil
+}
+
+// newDNSResolver builds a resolver querying the given servers, each new query goes
+// to the next server so a retried lookup falls back to another one
+func newDNSResolver(servers []string) *net.Resolver {
+	var next atomic.Uint32
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			server := servers[int(next.Add(1)-1)%len(servers)]
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// dnsResolver returns the resolver used for host names
+func (c *Client) dnsResolver() *net.Resolver {
+	if c.resolver != nil {
+		return c.resolver
+	}
+	return net.DefaultResolver
+}
+
+// ipNetwork restricts a network name (tcp, udp or ip) to the selected IP version
+func (c *Client) ipNetwork(network string) string {
+	if c.ipVersion == IPVersionAny || strings.HasSuffix(network, "4") || strings.HasSuffix(network, "6") {
+		return network
+	}
+	return network + c.ipVersion
+}
+
+// matchesIPVersion reports whether ip can be used with the selected IP version
+func (c *Client) matchesIPVersion(ip net.IP) bool {
+	switch c.ipVersion {
+	case IPVersion4:
+		return ip.To4() != nil
+	case IPVersion6:
+		return ip.To4() == nil
+	}
+	return true
+}
+
+// applyConnectTo returns the address to connect to in place of addr
+func (c *Client) applyConnectTo(addr string) string {
+	if c.overrides == nil {
+		return addr
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	for _, rule := range c.overrides.connectTo {
+		if (rule.host != "" && rule.host != strings.ToLower(host)) || (rule.port != "" && rule.port != port) {
+			continue
+		}
+		if rule.toHost != "" {
+			host = rule.toHost
+		}
+		if rule.toPort != "" {
+			port = rule.toPort
+		}
+		return net.JoinHostPort(host, port)
+	}
+	return addr
+}
+
+// pinnedAddrs returns the addresses of the selected IP version addr is pinned to with
+// a resolve entry, or nil when it is not pinned
+func (c *Client) pinnedAddrs(addr string) ([]string, error) {
+	if c.overrides == nil {
+		return nil, nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, nil
+	}
+	pinned := c.overrides.resolve[overrideKey(host, port)]
+	if pinned == nil {
+		return nil, nil
+	}
+
+	var addrs []string
+	for _, pinnedAddr := range pinned {
+		pinnedHost, _, _ := net.SplitHostPort(pinnedAddr)
+		if c.matchesIPVersion(net.ParseIP(pinnedHost)) {
+			addrs = append(addrs, pinnedAddr)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no IPv%s address pinned for %s", c.ipVersion, host)
+	}
+	return addrs, nil
+}
+
+// resolveAddr applies the host overrides to host:port and resolves it to a single
+// IP address, for the dialers that do not resolve names themselves. The lookup is
+// reported to the request trace through ctx.
+func (c *Client) resolveAddr(ctx context.Context, addr string) (string, error) {
+	addr = c.applyConnectTo(addr)
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+
+	pinned, err := c.pinnedAddrs(addr)
+	if err != nil {
+		return "", err
+	}
+	if pinned != nil {
+		return pinned[0], nil
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return addr, nil
+	}
+
+	ips, err := c.dnsResolver().LookupIP(ctx, c.ipNetwork("ip"), host)
+	if err != nil {
+		return "", err
+	}
+	if len(ips) == 0 {
+		return "", fmt.Errorf("no address found for %s", host)
+	}
+	return net.JoinHostPort(ips[0].String(), port), nil
+}
+
+// splitHostFields splits a colon separated rule into at most n fields,
+// colons inside brackets (IPv6 addresses) do not separate fields
+func splitHostFields(rule string, n int) []string {
+	var fields []string
+	start, depth := 0, 0
+	for i, r := range rule {
+		switch r {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ':':
+			if depth == 0 && len(fields) < n-1 {
+				fields = append(fields, rule[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(fields, rule[start:])
+}
+
+// overrideKey returns the lookup key of a host and port
+func overrideKey(host, port string) string {
+	return net.JoinHostPort(strings.ToLower(strings.Trim(host, "[]")), port)
+}
+
+// validPort reports whether port is a valid TCP or UDP port number
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n < 65536
+}
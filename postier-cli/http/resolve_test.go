@@ -0,0 +1,156 @@
+package http
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResolveEntries(t *testing.T) {
+	for _, test := range []struct {
+		entry   string
+		key     string
+		want    []string
+		wantErr string
+	}{
+		{"api.test:443:127.0.0.1", "api.test:443", []string{"127.0.0.1:443"}, ""},
+		{"API.test:8443:[::1],10.0.0.1", "api.test:8443", []string{"[::1]:8443", "10.0.0.1:8443"}, ""},
+		{"api.test:443: ::1 ", "api.test:443", []string{"[::1]:443"}, ""},
+		{"[::1]:443:127.0.0.1", "[::1]:443", []string{"127.0.0.1:443"}, ""},
+		{"api.test:443", "", nil, "expected host:port:addr"},
+		{":443:127.0.0.1", "", nil, "expected host:port:addr"},
+		{"api.test:https:127.0.0.1", "", nil, "expected host:port:addr"},
+		{"api.test:70000:127.0.0.1", "", nil, "expected host:port:addr"},
+		{"api.test:443:localhost", "", nil, `invalid address "localhost"`},
+	} {
+		t.Run(test.entry, func(t *testing.T) {
+			c := &Client{resolve: []string{test.entry}}
+			err := c.initResolver()
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("got %v, want an error with %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.overrides.resolve[test.key]; fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("got %v for %s, want %v", got, test.key, test.want)
+			}
+		})
+	}
+}
+
+func TestConnectTo(t *testing.T) {
+	for _, test := range []struct {
+		entry   string
+		addr    string
+		want    string
+		wantErr string
+	}{
+		{"api.test:443:backend.test:8443", "api.test:443", "backend.test:8443", ""},
+		{"api.test:443:backend.test:8443", "API.test:443", "backend.test:8443", ""},
+		{"api.test:443:backend.test:8443", "api.test:80", "api.test:80", ""},
+		{"api.test::backend.test:", "api.test:8080", "backend.test:8080", ""},
+		{":80::8080", "any.test:80", "any.test:8080", ""},
+		{"[::1]:443:[::2]:444", "[::1]:443", "[::2]:444", ""},
+		{"api.test:443:backend.test", "", "", "expected host1:port1:host2:port2"},
+		{"api.test:https:backend.test:443", "", "", "expected host1:port1:host2:port2"},
+	} {
+		t.Run(test.entry+" "+test.addr, func(t *testing.T) {
+			c := &Client{connectTo: []string{test.entry}}
+			err := c.initResolver()
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("got %v, want an error with %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.applyConnectTo(test.addr); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+// hostServer answers with the Host header it received
+func hostServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	t.Cleanup(server.Close)
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	return server, port
+}
+
+func TestHostOverrides(t *testing.T) {
+	_, port := hostServer(t)
+
+	for _, test := range []struct {
+		name    string
+		opts    []Option
+		url     string
+		wantErr string
+	}{
+		{"resolve", []Option{WithResolve("api.test:" + port + ":127.0.0.1")}, "http://api.test:" + port, ""},
+		{"resolve with fallback", []Option{WithResolve("api.test:" + port + ":[::1],127.0.0.1"), WithIPVersion(IPVersion4)}, "http://api.test:" + port, ""},
+		{"resolve other version", []Option{WithResolve("api.test:" + port + ":127.0.0.1"), WithIPVersion(IPVersion6)}, "http://api.test:" + port, "no IPv6 address pinned for api.test"},
+		{"connect to", []Option{WithConnectTo("api.test:80:127.0.0.1:" + port)}, "http://api.test", ""},
+		{"connect to pinned", []Option{WithConnectTo("api.test:80:backend.test:" + port), WithResolve("backend.test:" + port + ":127.0.0.1")}, "http://api.test", ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := NewClient(test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(&Request{Method: "GET", URL: test.url})
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("got %v, want an error with %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The Host header keeps the name of the URL
+			if want := strings.TrimPrefix(test.url, "http://"); resp.Body != want {
+				t.Errorf("server got Host %q, want %q", resp.Body, want)
+			}
+		})
+	}
+}
+
+func TestHostOverridesSkipProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("proxy for " + r.Host))
+	}))
+	defer proxy.Close()
+	proxyHost, proxyPort, _ := net.SplitHostPort(proxy.Listener.Addr().String())
+
+	// The rules match the address of the proxy, they apply to the server only
+	client, err := NewClient(
+		WithProxy("http://"+proxy.Listener.Addr().String()),
+		WithConnectTo(proxyHost+":"+proxyPort+":127.0.0.1:1"),
+		WithResolve(proxyHost+":"+proxyPort+":[::1]"),
+		WithIPVersion(IPVersion4),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(&Request{Method: "GET", URL: "http://api.test/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Body != "proxy for api.test" {
+		t.Errorf("got %q, want the answer of the proxy", resp.Body)
+	}
+}
@@ -0,0 +1,157 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultRetryDelay is the delay before the first retry when none is set
+const DefaultRetryDelay = time.Second
+
+// maxRetryDelay caps the exponential backoff and the Retry-After delay
+const maxRetryDelay = 2 * time.Minute
+
+// RetryPolicy controls whether and how failed requests are sent again
+type RetryPolicy struct {
+	Retries  int                                        // Number of retries after the first attempt, zero disables retries
+	Delay    time.Duration                              // Delay before the first retry, doubled for each following one
+	RetryAll bool                                       // Also retry methods that are not idempotent, such as POST and PATCH
+	OnRetry  func(attempt Attempt, delay time.Duration) // Called after a failed attempt, before waiting
+}
+
+// Attempt represents one try of a request
+type Attempt struct {
+	Number     int           `json:"number"`
+	StatusCode int           `json:"status_code,omitempty"` // Status code, zero when the request failed
+	Error      string        `json:"error,omitempty"`       // Failure reason, empty when a response was received
+	Timings    *HTTPTimings  `json:"timings,omitempty"`
+	Delay      time.Duration `json:"delay,omitempty"` // Wait before the next attempt
+}
+
+// retryableStatus lists the status codes worth sending the request again for
+var retryableStatus = map[int]bool{
+	http.StatusRequestTimeout:      true,
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// idempotentMethods are retried without RetryAll
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// WithRetryPolicy sets how failed requests are retried
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// canRetry reports whether the request method may be sent again
+func (p RetryPolicy) canRetry(method string) bool {
+	return p.Retries > 0 && (p.RetryAll || idempotentMethods[strings.ToUpper(method)])
+}
+
+// backoff returns the delay before the given retry (1 for the first one), an exponential
+// backoff with jitter, or the server's Retry-After when present. Both are capped to maxRetryDelay.
+func (p RetryPolicy) backoff(retry int, resp *Response) time.Duration {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if delay, ok := parseRetryAfter(resp.Headers["Retry-After"]); ok {
+			// Wait what the server asked, plus up to a tenth so the clients it throttled do not come back together
+			delay += time.Duration(rand.Int63n(int64(delay/10) + 1))
+			return min(delay, maxRetryDelay)
+		}
+	}
+
+	delay := p.Delay
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+	for i := 1; i < retry && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+
+	// Jitter: wait between half and the full delay so clients do not retry in lockstep
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// parseRetryAfter parses a Retry-After value, either seconds or an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// permanentError marks a failure that must not be retried whatever its cause
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// isRetryableError reports whether a failed attempt is worth sending again,
+// network failures are, certificate or request errors are not
+func isRetryableError(err error) bool {
+	var permanentErr *permanentError
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	var timeoutErr *TimeoutError
+	switch {
+	case errors.As(err, &permanentErr):
+		return false
+	case errors.As(err, &timeoutErr):
+		return true
+	case errors.As(err, &dnsErr):
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	case errors.As(err, &opErr):
+		return true
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, syscall.ECONNRESET):
+		return true
+	}
+	return false
+}
+
+// sleepContext waits for the delay or until ctx is done
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,179 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	for _, test := range []struct {
+		value    string
+		min, max time.Duration
+		ok       bool
+	}{
+		{"120", 2 * time.Minute, 2 * time.Minute, true},
+		{" 5 ", 5 * time.Second, 5 * time.Second, true},
+		{"0", 0, 0, true},
+		{time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat), 28 * time.Second, 30 * time.Second, true},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0, 0, true}, // In the past, retry now
+		{"soon", 0, 0, false},
+		{"-1", 0, 0, false},
+		{"1.5", 0, 0, false},
+		{"", 0, 0, false},
+	} {
+		delay, ok := parseRetryAfter(test.value)
+		if ok != test.ok || delay < test.min || delay > test.max {
+			t.Errorf("%q: got %s %t, want %s to %s %t", test.value, delay, ok, test.min, test.max, test.ok)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{Retries: 10, Delay: time.Second}
+	for _, test := range []struct {
+		name     string
+		retry    int
+		resp     *Response
+		min, max time.Duration
+	}{
+		{"first retry", 1, nil, 500 * time.Millisecond, time.Second},
+		{"third retry", 3, nil, 2 * time.Second, 4 * time.Second},
+		{"capped backoff", 20, nil, maxRetryDelay / 2, maxRetryDelay},
+		{"retry after", 1, &Response{StatusCode: 503, Headers: map[string]string{"Retry-After": "10"}}, 10 * time.Second, 11 * time.Second},
+		{"capped retry after", 1, &Response{StatusCode: 429, Headers: map[string]string{"Retry-After": "86400"}}, maxRetryDelay, maxRetryDelay},
+		{"retry after ignored on 500", 1, &Response{StatusCode: 500, Headers: map[string]string{"Retry-After": "60"}}, 500 * time.Millisecond, time.Second},
+	} {
+		for i := 0; i < 20; i++ {
+			if delay := policy.backoff(test.retry, test.resp); delay < test.min || delay > test.max {
+				t.Errorf("%s: got %s, want %s to %s", test.name, delay, test.min, test.max)
+				break
+			}
+		}
+	}
+}
+
+func TestCanRetry(t *testing.T) {
+	for _, test := range []struct {
+		policy RetryPolicy
+		method string
+		want   bool
+	}{
+		{RetryPolicy{Retries: 1}, "GET", true},
+		{RetryPolicy{Retries: 1}, "put", true},
+		{RetryPolicy{Retries: 1}, "DELETE", true},
+		{RetryPolicy{Retries: 1}, "POST", false},
+		{RetryPolicy{Retries: 1}, "PATCH", false},
+		{RetryPolicy{Retries: 1, RetryAll: true}, "POST", true},
+		{RetryPolicy{Retries: 0, RetryAll: true}, "GET", false},
+	} {
+		if got := test.policy.canRetry(test.method); got != test.want {
+			t.Errorf("%s with %+v: got %t, want %t", test.method, test.policy, got, test.want)
+		}
+	}
+}
+
+// flakyServer answers with the given statuses in turn, then 200
+func flakyServer(t *testing.T, statuses ...int) (*httptest.Server, func() int) {
+	t.Helper()
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		n := requests
+		mu.Unlock()
+		if n <= len(statuses) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(statuses[n-1])
+			w.Write([]byte("try again"))
+			return
+		}
+		w.Write([]byte("done"))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func TestRetry(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		method     string
+		policy     RetryPolicy
+		statuses   []int
+		wantStatus int
+		wantSent   int
+	}{
+		{"503 then 200", "GET", RetryPolicy{Retries: 2}, []int{503}, 200, 2},
+		{"retries exhausted", "GET", RetryPolicy{Retries: 2}, []int{503, 502, 500}, 500, 3},
+		{"not retryable status", "GET", RetryPolicy{Retries: 2}, []int{404}, 404, 1},
+		{"post not retried", "POST", RetryPolicy{Retries: 2}, []int{503}, 503, 1},
+		{"post with retry all", "POST", RetryPolicy{Retries: 2, RetryAll: true}, []int{503}, 200, 2},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			server, sent := flakyServer(t, test.statuses...)
+			var retries []Attempt
+			test.policy.Delay = time.Millisecond
+			test.policy.OnRetry = func(attempt Attempt, delay time.Duration) {
+				retries = append(retries, attempt)
+			}
+			client, err := NewClient(WithRetryPolicy(test.policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(&Request{Method: test.method, URL: server.URL, Body: "data", BodyType: "text"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != test.wantStatus || sent() != test.wantSent {
+				t.Errorf("got %d after %d requests, want %d after %d", resp.StatusCode, sent(), test.wantStatus, test.wantSent)
+			}
+			if len(resp.Attempts) != test.wantSent || len(retries) != test.wantSent-1 {
+				t.Errorf("got %d attempts and %d retries, want %d attempts", len(resp.Attempts), len(retries), test.wantSent)
+			}
+			for i, attempt := range retries {
+				if attempt.Number != i+1 || attempt.StatusCode != test.statuses[i] {
+					t.Errorf("retry %d: got attempt %d with %d, want %d", i, attempt.Number, attempt.StatusCode, test.statuses[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRetryConnectionError(t *testing.T) {
+	// The first connection is closed before any response
+	var mu sync.Mutex
+	dropped := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		drop := !dropped
+		dropped = true
+		mu.Unlock()
+		if drop {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Write([]byte("done"))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(WithRetryPolicy(RetryPolicy{Retries: 1, Delay: time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(&Request{Method: "GET", URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Body != "done" || len(resp.Attempts) != 2 || resp.Attempts[0].Error == "" {
+		t.Errorf("got %q with attempts %+v, want done after a failed attempt", resp.Body, resp.Attempts)
+	}
+}
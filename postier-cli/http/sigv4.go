@@ -0,0 +1,319 @@
+package http
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWS Signature Version 4 constants
+const (
+	sigV4Algorithm    = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat   = "20060102T150405Z"
+	sigV4UnsignedBody = "UNSIGNED-PAYLOAD"
+)
+
+// sigV4IgnoredHeaders are left out of the signature, proxies and the transport may change them
+var sigV4IgnoredHeaders = map[string]bool{
+	"authorization":     true,
+	"user-agent":        true,
+	"x-amzn-trace-id":   true,
+	"expect":            true,
+	"transfer-encoding": true,
+	"connection":        true,
+}
+
+// AWSCredentials are the access keys requests are signed with
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // Temporary credentials only, sent in X-Amz-Security-Token
+}
+
+// AWSSigV4 signs requests with AWS Signature Version 4 for a region and a service, e.g. eu-west-1 and s3
+type AWSSigV4 struct {
+	Region      string
+	Service     string
+	Credentials AWSCredentials
+}
+
+// ParseAWSScope parses a region/service pair such as us-east-1/execute-api
+func ParseAWSScope(scope string) (region, service string, err error) {
+	region, service, found := strings.Cut(scope, "/")
+	if !found || region == "" || service == "" || strings.Contains(service, "/") {
+		return "", "", fmt.Errorf("invalid AWS scope %q, expected region/service, e.g. us-east-1/s3", scope)
+	}
+	return region, service, nil
+}
+
+// LoadAWSCredentials reads the access keys from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or
+// from the shared credentials and config files for the profile in AWS_PROFILE (default otherwise)
+func LoadAWSCredentials() (*AWSCredentials, error) {
+	keyID, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	switch {
+	case keyID != "" && secret != "":
+		return &AWSCredentials{AccessKeyID: keyID, SecretAccessKey: secret, SessionToken: os.Getenv("AWS_SESSION_TOKEN")}, nil
+	case keyID != "":
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID is set without AWS_SECRET_ACCESS_KEY")
+	case secret != "":
+		return nil, fmt.Errorf("AWS_SECRET_ACCESS_KEY is set without AWS_ACCESS_KEY_ID")
+	}
+
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	home, _ := os.UserHomeDir()
+	credentialsFile := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if credentialsFile == "" {
+		credentialsFile = filepath.Join(home, ".aws", "credentials")
+	}
+	configFile := os.Getenv("AWS_CONFIG_FILE")
+	if configFile == "" {
+		configFile = filepath.Join(home, ".aws", "config")
+	}
+
+	// The config file names its sections "profile name", except for the default profile
+	configSection := "profile " + profile
+	if profile == "default" {
+		configSection = "default"
+	}
+	for _, file := range []struct{ path, section string }{
+		{credentialsFile, profile},
+		{configFile, configSection},
+	} {
+		values, err := readINISection(file.path, file.section)
+		if err != nil {
+			return nil, err
+		}
+		if values["aws_access_key_id"] == "" {
+			continue
+		}
+		if values["aws_secret_access_key"] == "" {
+			return nil, fmt.Errorf("AWS profile '%s' in %s has no aws_secret_access_key", profile, file.path)
+		}
+		return &AWSCredentials{
+			AccessKeyID:     values["aws_access_key_id"],
+			SecretAccessKey: values["aws_secret_access_key"],
+			SessionToken:    values["aws_session_token"],
+		}, nil
+	}
+	return nil, fmt.Errorf("no AWS credentials: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or add the profile '%s' to %s", profile, credentialsFile)
+}
+
+// readINISection returns the key = value pairs of a section of an INI file, none when the file does not exist
+func readINISection(path, section string) (map[string]string, error) {
+	values := make(map[string]string)
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AWS credentials: %w", err)
+	}
+	defer file.Close()
+
+	current := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			current = strings.Join(strings.Fields(line[1:len(line)-1]), " ")
+		case current == section:
+			if key, value, found := strings.Cut(line, "="); found {
+				values[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read AWS credentials: %w", err)
+	}
+	return values, nil
+}
+
+// validate checks the signing scope and the credentials
+func (s *AWSSigV4) validate() error {
+	if s.Region == "" || s.Service == "" {
+		return fmt.Errorf("AWS signature needs a region and a service")
+	}
+	if s.Credentials.AccessKeyID == "" || s.Credentials.SecretAccessKey == "" {
+		return fmt.Errorf("AWS signature needs an access key id and a secret access key")
+	}
+	return nil
+}
+
+// sign adds the X-Amz-Date and Authorization headers of the request, it runs once the
+// request is built so the signature covers what is sent. Every header present is signed,
+// except those the transport or a proxy may rewrite.
+func (s *AWSSigV4) sign(req *http.Request, now time.Time) error {
+	payloadHash, err := s.payloadHash(req)
+	if err != nil {
+		return err
+	}
+
+	amzDate := now.UTC().Format(sigV4TimeFormat)
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.Credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.Credentials.SessionToken)
+	}
+	// S3 needs the payload hash in a header, it is signed as well
+	if s.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string][]string{"host": {host}}
+	for key, values := range req.Header {
+		name := strings.ToLower(key)
+		if !sigV4IgnoredHeaders[name] {
+			headers[name] = append(headers[name], values...)
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		values := make([]string, len(headers[name]))
+		for i, value := range headers[name] {
+			values[i] = strings.Join(strings.Fields(value), " ")
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.Join(values, ",") + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		sigV4Path(req.URL.EscapedPath(), s.Service),
+		sigV4Query(req.URL.RawQuery),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/" + s.Service + "/aws4_request"
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.Credentials.SecretAccessKey), date)
+	for _, part := range []string{s.Region, s.Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, s.Credentials.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+// payloadHash returns the hex SHA-256 of the body, read from a copy of it. A body that
+// cannot be read twice, such as the standard input, is only accepted unsigned by S3.
+func (s *AWSSigV4) payloadHash(req *http.Request) (string, error) {
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	if hasBody && req.GetBody == nil && s.Service == "s3" {
+		return sigV4UnsignedBody, nil
+	}
+	hasher := sha256.New()
+	if err := hashBody(req, hasher, "AWS signature"); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// sigV4Path returns the canonical path of an escaped path. Each segment is decoded and encoded
+// again, so an encoded slash stays in its segment. S3 paths are encoded once and kept as is, the
+// other services drop the dot segments and duplicate slashes and encode the encoded path again.
+func sigV4Path(escapedPath, service string) string {
+	segments := strings.Split(escapedPath, "/")
+	canonical := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segment = unescaped
+		}
+		if service != "s3" {
+			switch segment {
+			case "", ".":
+				continue
+			case "..":
+				if len(canonical) > 0 {
+					canonical = canonical[:len(canonical)-1]
+				}
+				continue
+			}
+		}
+		canonical = append(canonical, sigV4Escape(segment, true))
+	}
+
+	if service == "s3" {
+		if path := strings.Join(canonical, "/"); path != "" {
+			return path
+		}
+		return "/"
+	}
+	path := "/" + strings.Join(canonical, "/")
+	if len(canonical) > 0 && strings.HasSuffix(escapedPath, "/") {
+		path += "/"
+	}
+	return sigV4Escape(path, false)
+}
+
+// sigV4Query returns the canonical query string: parameters sorted by name then value, strictly encoded
+func sigV4Query(rawQuery string) string {
+	values, _ := url.ParseQuery(rawQuery)
+	params := make([]string, 0, len(values))
+	for key, list := range values {
+		for _, value := range list {
+			params = append(params, sigV4Escape(key, true)+"="+sigV4Escape(value, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// sigV4Escape percent-encodes every byte but the unreserved characters of RFC 3986, a slash
+// is kept unless encodeSlash is set
+func sigV4Escape(value string, encodeSlash bool) string {
+	var escaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			escaped.WriteByte(c)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
+
+// sha256Hex returns the hex SHA-256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	io.WriteString(mac, data)
+	return mac.Sum(nil)
+}
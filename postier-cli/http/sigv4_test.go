@@ -0,0 +1,177 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Credentials, scope and date of the aws-sig-v4-test-suite
+var sigV4Suite = &AWSSigV4{
+	Region:  "us-east-1",
+	Service: "service",
+	Credentials: AWSCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	},
+}
+
+func TestSigV4Suite(t *testing.T) {
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	for _, test := range []struct {
+		name          string
+		method        string
+		url           string
+		headers       map[string]string
+		body          string
+		signedHeaders string
+		signature     string
+	}{
+		{
+			name:          "get-vanilla",
+			method:        "GET",
+			url:           "https://example.amazonaws.com/",
+			signedHeaders: "host;x-amz-date",
+			signature:     "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:          "get-relative",
+			method:        "GET",
+			url:           "https://example.amazonaws.com/example/..",
+			signedHeaders: "host;x-amz-date",
+			signature:     "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:          "get-relative-relative",
+			method:        "GET",
+			url:           "https://example.amazonaws.com/example1/example2/../..",
+			signedHeaders: "host;x-amz-date",
+			signature:     "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:          "get-slash",
+			method:        "GET",
+			url:           "https://example.amazonaws.com//",
+			signedHeaders: "host;x-amz-date",
+			signature:     "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:          "get-slash-dot-slash",
+			method:        "GET",
+			url:           "https://example.amazonaws.com/./",
+			signedHeaders: "host;x-amz-date",
+			signature:     "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:          "get-vanilla-query-order-key-case",
+			method:        "GET",
+			url:           "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			signedHeaders: "host;x-amz-date",
+			signature:     "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			name:          "get-vanilla-query-unreserved",
+			method:        "GET",
+			url:           "https://example.amazonaws.com/?-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz=-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+			signedHeaders: "host;x-amz-date",
+			signature:     "9c3e54bfcdf0b19771a7f523ee5669cdf59bc7cc0884027167c21bb143a40197",
+		},
+		{
+			name:          "post-vanilla",
+			method:        "POST",
+			url:           "https://example.amazonaws.com/",
+			signedHeaders: "host;x-amz-date",
+			signature:     "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+		},
+		{
+			name:          "post-header-key-sort",
+			method:        "POST",
+			url:           "https://example.amazonaws.com/",
+			headers:       map[string]string{"My-Header1": "value1"},
+			signedHeaders: "host;my-header1;x-amz-date",
+			signature:     "c5410059b04c1ee005303aed430f6e6645f61f4dc9e1461ec8f8916fdf18852c",
+		},
+		{
+			name:          "post-x-www-form-urlencoded",
+			method:        "POST",
+			url:           "https://example.amazonaws.com/",
+			headers:       map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			body:          "Param1=value1",
+			signedHeaders: "content-type;host;x-amz-date",
+			signature:     "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var body io.Reader
+			if test.body != "" {
+				body = strings.NewReader(test.body)
+			}
+			req, err := http.NewRequest(test.method, test.url, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, value := range test.headers {
+				req.Header.Set(key, value)
+			}
+			if err := sigV4Suite.sign(req, now); err != nil {
+				t.Fatal(err)
+			}
+
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=" + test.signedHeaders + ", Signature=" + test.signature
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("got  %s\nwant %s", got, want)
+			}
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("got X-Amz-Date %s, want 20150830T123600Z", got)
+			}
+		})
+	}
+}
+
+func TestSigV4Path(t *testing.T) {
+	for _, test := range []struct {
+		path, service, want string
+	}{
+		{"", "service", "/"},
+		{"", "s3", "/"},
+		{"/documents%20and%20settings/", "service", "/documents%2520and%2520settings/"},
+		{"/documents%20and%20settings/", "s3", "/documents%20and%20settings/"},
+		{"/photos/a+b=c.jpg", "s3", "/photos/a%2Bb%3Dc.jpg"},
+		{"/-._~", "service", "/-._~"},
+		{"/%7Euser", "service", "/~user"},
+		{"/a%2Fb", "service", "/a%252Fb"},
+		{"/a%2Fb", "s3", "/a%2Fb"},
+		{"/a/./b/../c//d/", "service", "/a/c/d/"},
+		{"/a/./b/../c//d/", "s3", "/a/./b/../c//d/"},
+		{"/a/b/..", "service", "/a"},
+		{"/../a", "service", "/a"},
+	} {
+		if got := sigV4Path(test.path, test.service); got != test.want {
+			t.Errorf("%s for %s: got %s, want %s", test.path, test.service, got, test.want)
+		}
+	}
+}
+
+func TestSigV4S3PayloadHash(t *testing.T) {
+	signer := &AWSSigV4{Region: "us-east-1", Service: "s3", Credentials: sigV4Suite.Credentials}
+	req, _ := http.NewRequest("PUT", "https://bucket.s3.amazonaws.com/key", strings.NewReader("Welcome to Amazon S3."))
+	if err := signer.sign(req, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := req.Header.Get("X-Amz-Content-Sha256"), "44ce7dd67c959e0d3524ffac1771dfbba87d2b6b4b4e99e42034a8b803f8b072"; got != want {
+		t.Errorf("got payload hash %s, want %s", got, want)
+	}
+
+	// A body that cannot be read twice is sent unsigned
+	req, _ = http.NewRequest("PUT", "https://bucket.s3.amazonaws.com/key", io.NopCloser(strings.NewReader("data")))
+	req.GetBody = nil
+	if err := signer.sign(req, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header.Get("X-Amz-Content-Sha256"); got != "UNSIGNED-PAYLOAD" {
+		t.Errorf("got payload hash %s, want UNSIGNED-PAYLOAD", got)
+	}
+}
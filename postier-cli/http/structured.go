@@ -0,0 +1,270 @@
+package http
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The subset of Structured Field Values (RFC 8941) used by message signatures and
+// Content-Digest: dictionaries of items and inner lists, with parameters
+
+// sfToken is a bare token, string values are quoted strings
+type sfToken string
+
+// sfParam is a parameter of an item or an inner list, its value is a string, an sfToken,
+// an int64, a []byte or a bool
+type sfParam struct {
+	key   string
+	value any
+}
+
+// sfItem is a bare item with its parameters
+type sfItem struct {
+	value  any
+	params []sfParam
+}
+
+// sfMember is a member of a dictionary, an item or an inner list
+type sfMember struct {
+	key    string
+	isList bool
+	item   sfItem   // Value of an item member
+	list   []sfItem // Items of an inner list member
+	params []sfParam
+}
+
+// sfParser reads structured field values from input
+type sfParser struct {
+	input string
+	pos   int
+}
+
+// parseSFDictionary parses a dictionary field, an empty field has no members
+func parseSFDictionary(input string) ([]sfMember, error) {
+	p := &sfParser{input: input}
+	p.skip(" \t")
+	var members []sfMember
+	for p.pos < len(p.input) {
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		member := sfMember{key: key}
+		if p.peek() == '=' {
+			p.pos++
+			if p.peek() == '(' {
+				member.isList = true
+				if member.list, err = p.parseInnerList(); err != nil {
+					return nil, err
+				}
+			} else if member.item.value, err = p.parseBareItem(); err != nil {
+				return nil, err
+			}
+		} else {
+			member.item.value = true
+		}
+		if member.params, err = p.parseParams(); err != nil {
+			return nil, err
+		}
+		if !member.isList {
+			member.item.params = member.params
+		}
+		members = append(members, member)
+
+		p.skip(" \t")
+		if p.pos == len(p.input) {
+			break
+		}
+		if p.peek() != ',' {
+			return nil, fmt.Errorf("expected a comma at %d", p.pos)
+		}
+		p.pos++
+		p.skip(" \t")
+		if p.pos == len(p.input) {
+			return nil, fmt.Errorf("trailing comma")
+		}
+	}
+	return members, nil
+}
+
+// peek returns the next character, 0 at the end of the input
+func (p *sfParser) peek() byte {
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+// skip moves past the characters in chars
+func (p *sfParser) skip(chars string) {
+	for p.pos < len(p.input) && strings.IndexByte(chars, p.input[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// parseKey reads a dictionary or parameter key
+func (p *sfParser) parseKey() (string, error) {
+	start := p.pos
+	if c := p.peek(); !(c >= 'a' && c <= 'z') && c != '*' {
+		return "", fmt.Errorf("expected a key at %d", p.pos)
+	}
+	for c := p.peek(); (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || (c != 0 && strings.IndexByte("_-.*", c) >= 0); c = p.peek() {
+		p.pos++
+	}
+	return p.input[start:p.pos], nil
+}
+
+// parseInnerList reads a parenthesized list of items and the parameters of each
+func (p *sfParser) parseInnerList() ([]sfItem, error) {
+	p.pos++ // (
+	items := []sfItem{}
+	for {
+		p.skip(" ")
+		if p.peek() == ')' {
+			p.pos++
+			return items, nil
+		}
+		value, err := p.parseBareItem()
+		if err != nil {
+			return nil, err
+		}
+		params, err := p.parseParams()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, sfItem{value: value, params: params})
+		if c := p.peek(); c != ' ' && c != ')' {
+			return nil, fmt.Errorf("expected a space or ) at %d", p.pos)
+		}
+	}
+}
+
+// parseParams reads the ;key=value parameters following an item or a list
+func (p *sfParser) parseParams() ([]sfParam, error) {
+	var params []sfParam
+	for p.peek() == ';' {
+		p.pos++
+		p.skip(" ")
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		param := sfParam{key: key, value: true}
+		if p.peek() == '=' {
+			p.pos++
+			if param.value, err = p.parseBareItem(); err != nil {
+				return nil, err
+			}
+		}
+		params = append(params, param)
+	}
+	return params, nil
+}
+
+// parseBareItem reads a string, a byte sequence, an integer, a boolean or a token
+func (p *sfParser) parseBareItem() (any, error) {
+	c := p.peek()
+	switch {
+	case c == '"':
+		var value strings.Builder
+		for p.pos++; p.pos < len(p.input); p.pos++ {
+			switch c := p.input[p.pos]; c {
+			case '\\':
+				p.pos++
+				if p.pos == len(p.input) || (p.input[p.pos] != '"' && p.input[p.pos] != '\\') {
+					return nil, fmt.Errorf("invalid escape in string")
+				}
+				value.WriteByte(p.input[p.pos])
+			case '"':
+				p.pos++
+				return value.String(), nil
+			default:
+				value.WriteByte(c)
+			}
+		}
+		return nil, fmt.Errorf("unterminated string")
+	case c == ':':
+		end := strings.IndexByte(p.input[p.pos+1:], ':')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated byte sequence")
+		}
+		data, err := base64.StdEncoding.DecodeString(p.input[p.pos+1 : p.pos+1+end])
+		if err != nil {
+			return nil, fmt.Errorf("invalid byte sequence: %w", err)
+		}
+		p.pos += end + 2
+		return data, nil
+	case c == '?':
+		if p.pos+1 < len(p.input) && (p.input[p.pos+1] == '0' || p.input[p.pos+1] == '1') {
+			p.pos += 2
+			return p.input[p.pos-1] == '1', nil
+		}
+		return nil, fmt.Errorf("invalid boolean at %d", p.pos)
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for c := p.peek(); c >= '0' && c <= '9'; c = p.peek() {
+			p.pos++
+		}
+		value, err := strconv.ParseInt(p.input[start:p.pos], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer at %d", start)
+		}
+		return value, nil
+	case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '*':
+		start := p.pos
+		for c := p.peek(); c > ' ' && c < 0x7f && strings.IndexByte(`"(),;<=>?@[\]{}`, c) < 0; c = p.peek() {
+			p.pos++
+		}
+		return sfToken(p.input[start:p.pos]), nil
+	}
+	return nil, fmt.Errorf("unexpected character at %d", p.pos)
+}
+
+// serializeInnerList serializes an inner list with its parameters
+func serializeInnerList(items []sfItem, params []sfParam) string {
+	serialized := make([]string, len(items))
+	for i, item := range items {
+		serialized[i] = serializeItem(item)
+	}
+	return "(" + strings.Join(serialized, " ") + ")" + serializeParams(params)
+}
+
+// serializeItem serializes an item with its parameters
+func serializeItem(item sfItem) string {
+	return serializeBareItem(item.value) + serializeParams(item.params)
+}
+
+// serializeParams serializes parameters, a true boolean is written as its key alone
+func serializeParams(params []sfParam) string {
+	var serialized strings.Builder
+	for _, param := range params {
+		serialized.WriteString(";" + param.key)
+		if param.value != true {
+			serialized.WriteString("=" + serializeBareItem(param.value))
+		}
+	}
+	return serialized.String()
+}
+
+// serializeBareItem serializes a string, a token, an integer, a byte sequence or a boolean
+func serializeBareItem(value any) string {
+	switch v := value.(type) {
+	case string:
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+	case sfToken:
+		return string(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case []byte:
+		return ":" + base64.StdEncoding.EncodeToString(v) + ":"
+	case bool:
+		if v {
+			return "?1"
+		}
+		return "?0"
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,170 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// TimeoutPhase names the phase of a request that was running when a time limit expired
+type TimeoutPhase string
+
+// Request phases reported by TimeoutError
+const (
+	PhaseDNS       TimeoutPhase = "DNS lookup"
+	PhaseConnect   TimeoutPhase = "connect"
+	PhaseProxy     TimeoutPhase = "proxy connect"
+	PhaseTLS       TimeoutPhase = "TLS handshake"
+	PhaseQUIC      TimeoutPhase = "QUIC handshake"
+	PhaseWrite     TimeoutPhase = "request write"
+	PhaseFirstByte TimeoutPhase = "first byte"
+	PhaseTransfer  TimeoutPhase = "transfer"
+)
+
+// TimeoutError is returned when a request exceeds one of its time limits
+type TimeoutError struct {
+	Phase TimeoutPhase  // Phase that stalled
+	Limit time.Duration // Time limit that expired
+	Name  string        // Name of the limit: max-time, connect-timeout, tls-timeout or response-header-timeout
+	Err   error         // Underlying error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timeout during %s (%s of %s exceeded)", e.Phase, e.Name, e.Limit)
+}
+
+func (e *TimeoutError) Unwrap() error { return e.Err }
+
+// Timeout reports true, so TimeoutError satisfies net.Error
+func (e *TimeoutError) Timeout() bool { return true }
+
+// Temporary reports false, the request is not retried by net/http
+func (e *TimeoutError) Temporary() bool { return false }
+
+// WithConnectTimeout limits the time spent resolving the host and establishing the connection,
+// SOCKS proxy handshake included
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.connectTimeout = timeout
+	}
+}
+
+// WithTLSTimeout limits the time spent in the TLS or QUIC handshake
+func WithTLSTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.tlsTimeout = timeout
+	}
+}
+
+// WithResponseHeaderTimeout limits the time spent waiting for the response headers once the request is written
+func WithResponseHeaderTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.responseHeaderTimeout = timeout
+	}
+}
+
+// errResponseHeaderTimeout is returned by headerTimeoutTransport when the response headers are late
+var errResponseHeaderTimeout error = &headerTimeoutError{}
+
+type headerTimeoutError struct{}
+
+func (*headerTimeoutError) Error() string   { return "timeout awaiting response headers" }
+func (*headerTimeoutError) Timeout() bool   { return true }
+func (*headerTimeoutError) Temporary() bool { return true }
+
+// headerTimeoutTransport limits the wait for the response headers once the request is written,
+// for the h2c and HTTP/3 transports which have no ResponseHeaderTimeout of their own
+type headerTimeoutTransport struct {
+	next    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *headerTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	var mu sync.Mutex
+	var timer *time.Timer
+	// The hook runs before the ones of the request trace, which are kept
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			if info.Err == nil && timer == nil {
+				timer = time.AfterFunc(t.timeout, cancel)
+			}
+		},
+	})
+
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	mu.Lock()
+	expired := timer != nil && !timer.Stop()
+	mu.Unlock()
+	if expired {
+		// Headers arriving as the limit expires come with a body that can no longer be read
+		if err == nil {
+			resp.Body.Close()
+		}
+		cancel()
+		return nil, errResponseHeaderTimeout
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped transport
+func (t *headerTimeoutTransport) CloseIdleConnections() {
+	closeIdleConnections(t.next)
+}
+
+// cancelBody releases the context of the request once its response body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// timeoutError converts a timeout into a TimeoutError naming the phase that stalled,
+// other errors are returned unchanged
+func (c *Client) timeoutError(ctx context.Context, trace *requestTrace, err error) error {
+	var netErr net.Error
+	if !errors.Is(err, context.DeadlineExceeded) && !(errors.As(err, &netErr) && netErr.Timeout()) {
+		return err
+	}
+
+	timeoutErr := &TimeoutError{Phase: trace.currentPhase(), Err: err}
+
+	// The overall limit wins when its deadline has passed
+	if c.timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		timeoutErr.Limit, timeoutErr.Name = c.timeout, "max-time"
+		return timeoutErr
+	}
+
+	switch timeoutErr.Phase {
+	case PhaseDNS, PhaseConnect, PhaseProxy:
+		timeoutErr.Limit, timeoutErr.Name = c.connectTimeout, "connect-timeout"
+	case PhaseTLS, PhaseQUIC:
+		timeoutErr.Limit, timeoutErr.Name = c.tlsTimeout, "tls-timeout"
+	case PhaseFirstByte:
+		timeoutErr.Limit, timeoutErr.Name = c.responseHeaderTimeout, "response-header-timeout"
+	}
+	if timeoutErr.Limit == 0 {
+		// The limit that fired is not one of ours, e.g. a deadline set by the caller's context
+		return err
+	}
+
+	return timeoutErr
+}
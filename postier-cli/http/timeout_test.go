@@ -0,0 +1,124 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// assertTimeout checks that err is a TimeoutError of the limit name during phase
+func assertTimeout(t *testing.T, err error, name string, phase TimeoutPhase) {
+	t.Helper()
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("got %v, want a timeout", err)
+	}
+	if timeoutErr.Name != name || timeoutErr.Phase != phase {
+		t.Errorf("got %s during %s, want %s during %s", timeoutErr.Name, timeoutErr.Phase, name, phase)
+	}
+}
+
+func TestResponseHeaderTimeout(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	})
+	server := httptest.NewServer(slow)
+	defer server.Close()
+	h2cServer := httptest.NewServer(h2c.NewHandler(slow, &http2.Server{}))
+	defer h2cServer.Close()
+
+	for _, test := range []struct {
+		version string
+		url     string
+	}{
+		{HTTPVersion11, server.URL},
+		{HTTPVersionH2C, h2cServer.URL},
+		{HTTPVersion3, startHTTP3Server(t, slow)},
+	} {
+		t.Run(test.version, func(t *testing.T) {
+			client, err := NewClient(
+				WithHTTPVersion(test.version),
+				WithInsecure(true),
+				WithResponseHeaderTimeout(100*time.Millisecond),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			_, err = client.Do(&Request{Method: "GET", URL: test.url})
+			assertTimeout(t, err, "response-header-timeout", PhaseFirstByte)
+		})
+	}
+}
+
+func TestTLSTimeout(t *testing.T) {
+	// The server accepts the connection and never answers the client hello
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	client, err := NewClient(WithTLSTimeout(100 * time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Do(&Request{Method: "GET", URL: "https://" + listener.Addr().String()})
+	assertTimeout(t, err, "tls-timeout", PhaseTLS)
+}
+
+func TestMaxTimeDuringTransfer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first part"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(WithTimeout(200*time.Millisecond), WithResponseHeaderTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Do(&Request{Method: "GET", URL: server.URL})
+	assertTimeout(t, err, "max-time", PhaseTransfer)
+}
+
+func TestTimeoutFromCallerContext(t *testing.T) {
+	// A deadline that is not one of the client limits is not reported as a TimeoutError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client, err := NewClient(WithConnectTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = client.DoContext(ctx, &Request{Method: "GET", URL: server.URL})
+	var timeoutErr *TimeoutError
+	if err == nil || errors.As(err, &timeoutErr) {
+		t.Errorf("got %v, want the error of the caller's deadline", err)
+	}
+}
@@ -0,0 +1,103 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WithInsecure disables verification of the server certificate chain and host name
+func WithInsecure(insecure bool) Option {
+	return func(c *Client) {
+		c.insecure = insecure
+	}
+}
+
+// WithCACert trusts the certificates of a PEM bundle instead of the system roots
+func WithCACert(file string) Option {
+	return func(c *Client) {
+		c.caCert = file
+	}
+}
+
+// WithCAPath trusts every PEM certificate found in a directory instead of the system roots
+func WithCAPath(dir string) Option {
+	return func(c *Client) {
+		c.caPath = dir
+	}
+}
+
+// newTLSConfig builds the TLS configuration from the client options
+func (c *Client) newTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.insecure}
+
+	if c.clientCert.CertFile != "" {
+		cert, err := loadClientCert(c.clientCert)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if c.caCert == "" && c.caPath == "" {
+		return tlsConfig, nil
+	}
+
+	// Like curl, a custom CA replaces the system roots
+	pool := x509.NewCertPool()
+	if c.caCert != "" {
+		if err := appendCertFile(pool, c.caCert); err != nil {
+			return nil, err
+		}
+	}
+	if c.caPath != "" {
+		if err := appendCertDir(pool, c.caPath); err != nil {
+			return nil, err
+		}
+	}
+	tlsConfig.RootCAs = pool
+
+	return tlsConfig, nil
+}
+
+// appendCertFile adds the certificates of a PEM file to the pool
+func appendCertFile(pool *x509.CertPool, file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read CA certificate file %s: %w", file, err)
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no valid PEM certificate found in %s", file)
+	}
+	return nil
+}
+
+// appendCertDir adds the certificates of every PEM file in a directory to the pool,
+// files that do not contain a certificate are ignored
+func appendCertDir(pool *x509.CertPool, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read CA directory %s: %w", dir, err)
+	}
+
+	found := false
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if pool.AppendCertsFromPEM(data) {
+			found = true
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("no valid PEM certificate found in %s", dir)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package http
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeCertPEM writes a certificate as a PEM file and returns its path
+func writeCertPEM(t *testing.T, dir, name string, cert *x509.Certificate) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestServerVerification(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	caDir := t.TempDir()
+	caFile := writeCertPEM(t, caDir, "server.pem", server.Certificate())
+	if err := os.WriteFile(filepath.Join(caDir, "README"), []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	otherCA := writeCertPEM(t, t.TempDir(), "other.pem", selfSignedCertificate(t))
+
+	for _, test := range []struct {
+		name     string
+		opts     []Option
+		verified bool
+	}{
+		{"system roots", nil, false},
+		{"cacert", []Option{WithCACert(caFile)}, true},
+		{"capath", []Option{WithCAPath(caDir)}, true},
+		{"other cacert", []Option{WithCACert(otherCA)}, false},
+		{"insecure", []Option{WithInsecure(true)}, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := NewClient(test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = client.Do(&Request{Method: "GET", URL: server.URL})
+			var unknownAuthority x509.UnknownAuthorityError
+			switch {
+			case test.verified && err != nil:
+				t.Errorf("got %v, want the server accepted", err)
+			case !test.verified && !errors.As(err, &unknownAuthority):
+				t.Errorf("got %v, want an unknown authority error", err)
+			}
+		})
+	}
+}
+
+func TestCAWithoutCertificate(t *testing.T) {
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(empty, []byte("nothing here"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, opt := range []Option{WithCACert(empty), WithCAPath(filepath.Dir(empty))} {
+		if _, err := NewClient(opt); err == nil || !strings.Contains(err.Error(), "no valid PEM certificate") {
+			t.Errorf("got %v, want no valid PEM certificate", err)
+		}
+	}
+}
+
+// selfSignedCertificate returns the parsed certificate of selfSignedCert
+func selfSignedCertificate(t *testing.T) *x509.Certificate {
+	t.Helper()
+	cert, err := x509.ParseCertificate(selfSignedCert(t).Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
@@ -0,0 +1,101 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// TLSInfo represents the parameters negotiated during the TLS handshake
+type TLSInfo struct {
+	Version          string            `json:"version"`               // Negotiated TLS version
+	CipherSuite      string            `json:"cipher_suite"`          // Negotiated cipher suite
+	ALPN             string            `json:"alpn,omitempty"`        // Application protocol negotiated with ALPN
+	ServerName       string            `json:"server_name,omitempty"` // Server name sent with SNI
+	Resumed          bool              `json:"resumed"`               // Session was resumed from a previous connection
+	OCSPStapled      bool              `json:"ocsp_stapled"`          // Server stapled an OCSP response
+	OCSPStatus       string            `json:"ocsp_status,omitempty"` // Status of the stapled OCSP response: good, revoked, unknown, invalid, or unverified without the issuer
+	PeerCertificates []CertificateInfo `json:"peer_certificates"`     // Certificate chain sent by the server, leaf first
+}
+
+// CertificateInfo represents the identifying fields of a certificate
+type CertificateInfo struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	DNSNames  []string  `json:"dns_names,omitempty"`
+	IPs       []string  `json:"ips,omitempty"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+}
+
+// newTLSInfo extracts the TLS details of a connection state
+func newTLSInfo(state *tls.ConnectionState) *TLSInfo {
+	if state == nil {
+		return nil
+	}
+
+	info := &TLSInfo{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ALPN:        state.NegotiatedProtocol,
+		ServerName:  state.ServerName,
+		Resumed:     state.DidResume,
+		OCSPStapled: len(state.OCSPResponse) > 0,
+	}
+
+	if info.OCSPStapled {
+		info.OCSPStatus = ocspStatus(state)
+	}
+
+	for _, cert := range state.PeerCertificates {
+		info.PeerCertificates = append(info.PeerCertificates, newCertificateInfo(cert))
+	}
+
+	return info
+}
+
+// newCertificateInfo extracts the identifying fields of a certificate
+func newCertificateInfo(cert *x509.Certificate) CertificateInfo {
+	info := CertificateInfo{
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		DNSNames:  cert.DNSNames,
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPs = append(info.IPs, ip.String())
+	}
+	return info
+}
+
+// ocspStatus parses the stapled OCSP response and returns the certificate status.
+// The signature of the response is checked with the issuer of the leaf, from the verified
+// chain or else from the certificates sent by the server; without it the status is unverified.
+func ocspStatus(state *tls.ConnectionState) string {
+	var issuer *x509.Certificate
+	switch {
+	case len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 1:
+		issuer = state.VerifiedChains[0][1]
+	case len(state.PeerCertificates) > 1:
+		issuer = state.PeerCertificates[1]
+	default:
+		return "unverified"
+	}
+
+	resp, err := ocsp.ParseResponseForCert(state.OCSPResponse, state.PeerCertificates[0], issuer)
+	if err != nil {
+		return "invalid"
+	}
+
+	switch resp.Status {
+	case ocsp.Good:
+		return "good"
+	case ocsp.Revoked:
+		return "revoked"
+	default:
+		return "unknown"
+	}
+}
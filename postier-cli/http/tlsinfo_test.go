@@ -0,0 +1,173 @@
+package http
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// testCA is a certificate authority issuing a certificate for 127.0.0.1
+type testCA struct {
+	cert      *x509.Certificate
+	key       crypto.Signer
+	leaf      *x509.Certificate
+	leafKey   crypto.Signer
+	otherKey  crypto.Signer // Key of no certificate, to sign invalid responses
+	notBefore time.Time
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	ca := &testCA{notBefore: time.Now().Add(-time.Hour)}
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+	ca.key, ca.leafKey, ca.otherKey = keys[0], keys[1], keys[2]
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Postier Test CA"},
+		NotBefore:             ca.notBefore,
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, keys[0].Public(), keys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.cert, _ = x509.ParseCertificate(der)
+
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    ca.notBefore,
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err = x509.CreateCertificate(rand.Reader, leafTemplate, ca.cert, keys[1].Public(), keys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.leaf, _ = x509.ParseCertificate(der)
+	return ca
+}
+
+// ocspResponse returns an OCSP response with status for the leaf, signed by signer
+func (ca *testCA) ocspResponse(t *testing.T, status int, signer crypto.Signer) []byte {
+	t.Helper()
+	template := ocsp.Response{
+		Status:       status,
+		SerialNumber: ca.leaf.SerialNumber,
+		ThisUpdate:   ca.notBefore,
+		NextUpdate:   time.Now().Add(time.Hour),
+	}
+	if status == ocsp.Revoked {
+		template.RevokedAt = ca.notBefore
+	}
+	resp, err := ocsp.CreateResponse(ca.cert, ca.cert, template, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestTLSInfo(t *testing.T) {
+	ca := newTestCA(t)
+	caFile := writeCertPEM(t, t.TempDir(), "ca.pem", ca.cert)
+
+	for _, test := range []struct {
+		name       string
+		status     int
+		signer     crypto.Signer
+		chain      bool // The server sends the CA with the leaf
+		trustCA    bool // The client verifies the server with the CA instead of --insecure
+		wantStatus string
+	}{
+		{"good with chain", ocsp.Good, ca.key, true, false, "good"},
+		{"good from verified chain", ocsp.Good, ca.key, false, true, "good"},
+		{"revoked", ocsp.Revoked, ca.key, true, false, "revoked"},
+		{"forged signature", ocsp.Good, ca.otherKey, true, false, "invalid"},
+		{"leaf only", ocsp.Good, ca.key, false, false, "unverified"},
+		{"forged leaf only", ocsp.Good, ca.otherKey, false, false, "unverified"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cert := tls.Certificate{
+				Certificate: [][]byte{ca.leaf.Raw},
+				PrivateKey:  ca.leafKey,
+				OCSPStaple:  ca.ocspResponse(t, test.status, test.signer),
+			}
+			if test.chain {
+				cert.Certificate = append(cert.Certificate, ca.cert.Raw)
+			}
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+			server.EnableHTTP2 = true
+			server.StartTLS()
+			defer server.Close()
+
+			opts := []Option{WithInsecure(true)}
+			if test.trustCA {
+				opts = []Option{WithCACert(caFile)}
+			}
+			client, err := NewClient(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(&Request{Method: "GET", URL: server.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			info := resp.TLS
+			if info == nil {
+				t.Fatal("no TLS details")
+			}
+			if info.Version != "TLS 1.3" || info.ALPN != "h2" || info.CipherSuite == "" {
+				t.Errorf("got %s %s %s, want TLS 1.3 with h2", info.Version, info.CipherSuite, info.ALPN)
+			}
+			if !info.OCSPStapled || info.OCSPStatus != test.wantStatus {
+				t.Errorf("got OCSP stapled %t status %q, want %q", info.OCSPStapled, info.OCSPStatus, test.wantStatus)
+			}
+			if len(info.PeerCertificates) == 0 || info.PeerCertificates[0].Subject != "CN=127.0.0.1" ||
+				info.PeerCertificates[0].Issuer != "CN=Postier Test CA" || info.PeerCertificates[0].IPs[0] != "127.0.0.1" {
+				t.Errorf("got peer certificates %+v", info.PeerCertificates)
+			}
+		})
+	}
+}
+
+func TestTLSInfoPlainHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(&Request{Method: "GET", URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.TLS != nil {
+		t.Errorf("got TLS details %+v over plain HTTP", resp.TLS)
+	}
+}
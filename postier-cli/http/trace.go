@@ -0,0 +1,235 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// requestTrace records the phase timings of a single request and reports them to the progress observer
+type requestTrace struct {
+	timings  *HTTPTimings
+	progress ProgressObserver
+
+	dnsStart, connectStart, tlsStart, quicStart, proxyStart, writeStart, firstByteStart time.Time
+
+	proxy       *url.URL // Proxy selected for the request, nil for a direct connection
+	proxyTunnel bool     // The proxy is asked to open a tunnel to the server (CONNECT or SOCKS)
+
+	hopStart  time.Time     // Start of the current redirect hop
+	redirects []RedirectHop // Redirects followed so far
+
+	mu         sync.Mutex
+	phase      TimeoutPhase   // Phase currently running, reported by timeouts
+	conn       ConnectionInfo // Connection details of the current hop
+	dialStarts []time.Time    // Start of each entry of conn.DialAttempts
+}
+
+// requestTraceKey is the context key of the request trace
+type requestTraceKey struct{}
+
+// newRequestTrace creates a trace writing into timings
+func newRequestTrace(timings *HTTPTimings, progress ProgressObserver) *requestTrace {
+	return &requestTrace{timings: timings, progress: progress, hopStart: time.Now(), phase: PhaseConnect}
+}
+
+// setPhase records the phase currently running
+func (t *requestTrace) setPhase(phase TimeoutPhase) {
+	t.mu.Lock()
+	t.phase = phase
+	t.mu.Unlock()
+}
+
+// currentPhase returns the phase currently running
+func (t *requestTrace) currentPhase() TimeoutPhase {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.phase
+}
+
+// withRequestTrace attaches the trace to the context, both as an httptrace.ClientTrace
+// and for the phases httptrace does not know about
+func withRequestTrace(ctx context.Context, t *requestTrace) context.Context {
+	ctx = context.WithValue(ctx, requestTraceKey{}, t)
+	return httptrace.WithClientTrace(ctx, t.clientTrace())
+}
+
+// contextRequestTrace returns the trace attached to the context, or nil
+func contextRequestTrace(ctx context.Context) *requestTrace {
+	t, _ := ctx.Value(requestTraceKey{}).(*requestTrace)
+	return t
+}
+
+// useProxy records the proxy selected for the request
+func (t *requestTrace) useProxy(proxyURL *url.URL, tunnel bool) {
+	if t == nil {
+		return
+	}
+	t.proxy, t.proxyTunnel = proxyURL, tunnel
+}
+
+// socksProxy returns the SOCKS proxy selected for the request, or nil
+func (t *requestTrace) socksProxy() *url.URL {
+	if t == nil || t.proxy == nil || !isSOCKS(t.proxy) {
+		return nil
+	}
+	return t.proxy
+}
+
+// httpProxy returns the HTTP proxy selected for the request, or nil
+func (t *requestTrace) httpProxy() *url.URL {
+	if t == nil || t.proxy == nil || isSOCKS(t.proxy) {
+		return nil
+	}
+	return t.proxy
+}
+
+// proxyConnectStart marks the beginning of the tunnel negotiation with the proxy
+func (t *requestTrace) proxyConnectStart() {
+	t.proxyStart = time.Now()
+	t.setPhase(PhaseProxy)
+	t.progress.Update("proxy_start", "started", 0)
+}
+
+// proxyConnectDone marks the end of the tunnel negotiation with the proxy
+func (t *requestTrace) proxyConnectDone() {
+	if t == nil || t.proxyStart.IsZero() {
+		return
+	}
+	t.timings.ProxyConnect = time.Since(t.proxyStart)
+	t.setPhase(PhaseFirstByte)
+	t.progress.Update("proxy_complete", "completed", t.timings.ProxyConnect)
+}
+
+// quicHandshakeStart marks the beginning of a QUIC handshake
+func (t *requestTrace) quicHandshakeStart() {
+	if t == nil {
+		return
+	}
+	t.quicStart = time.Now()
+	t.setPhase(PhaseQUIC)
+	t.progress.Update("quic_start", "started", 0)
+}
+
+// quicHandshakeDone marks the end of a QUIC handshake
+func (t *requestTrace) quicHandshakeDone() {
+	if t == nil || t.quicStart.IsZero() {
+		return
+	}
+	t.timings.QUICHandshake = time.Since(t.quicStart)
+	t.setPhase(PhaseFirstByte)
+	t.progress.Update("quic_complete", "completed", t.timings.QUICHandshake)
+}
+
+// requestWrite returns the request write time, which the transport may record while the response is read
+func (t *requestTrace) requestWrite() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.timings.RequestWrite
+}
+
+// clientTrace creates the httptrace hooks recording the standard phases
+func (t *requestTrace) clientTrace() *httptrace.ClientTrace {
+	timings, progress := t.timings, t.progress
+
+	return &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			t.dnsStart = time.Now()
+			t.setPhase(PhaseDNS)
+			progress.Update("dns_start", "started", 0)
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			t.setPhase(PhaseConnect)
+			t.dnsResolved(info.Addrs)
+			if !t.dnsStart.IsZero() {
+				timings.DNSLookup = time.Since(t.dnsStart)
+				progress.Update("dns_complete", "completed", timings.DNSLookup)
+			}
+		},
+		ConnectStart: func(network, addr string) {
+			t.connectStart = time.Now()
+			t.setPhase(PhaseConnect)
+			t.dialStarted(network, addr)
+			progress.Update("connect_start", "started", 0)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.dialDone(network, addr, err)
+			if err == nil {
+				t.setPhase(PhaseFirstByte)
+			}
+			if !t.connectStart.IsZero() {
+				timings.TCPConnection = time.Since(t.connectStart)
+				progress.Update("connect_complete", "completed", timings.TCPConnection)
+			}
+			// Connected to the proxy, the tunnel to the server comes next
+			if err == nil && t.proxyTunnel {
+				t.proxyConnectStart()
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.gotConn(info)
+		},
+		TLSHandshakeStart: func() {
+			t.tlsStart = time.Now()
+			t.setPhase(PhaseTLS)
+			progress.Update("tls_start", "started", 0)
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err == nil {
+				t.setPhase(PhaseFirstByte)
+			}
+			if !t.tlsStart.IsZero() {
+				timings.TLSHandshake = time.Since(t.tlsStart)
+				progress.Update("tls_complete", "completed", timings.TLSHandshake)
+			}
+		},
+		WroteHeaders: func() {
+			t.mu.Lock()
+			t.writeStart = time.Now()
+			t.mu.Unlock()
+			t.setPhase(PhaseWrite)
+			progress.Update("write_start", "started", 0)
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			// The body may still be written after the server answered, or redirected the request
+			t.mu.Lock()
+			written := !t.writeStart.IsZero()
+			if written {
+				timings.RequestWrite = time.Since(t.writeStart)
+			}
+			requestWrite := timings.RequestWrite
+			t.mu.Unlock()
+			if written {
+				progress.Update("write_complete", "completed", requestWrite)
+			}
+			if info.Err == nil {
+				t.setPhase(PhaseFirstByte)
+			}
+			progress.Update("request_sent", "completed", 0)
+		},
+		GotFirstResponseByte: func() {
+			if t.firstByteStart.IsZero() {
+				t.firstByteStart = time.Now()
+			}
+			t.setPhase(PhaseTransfer)
+			switch {
+			case !t.connectStart.IsZero():
+				timings.ServerTime = t.firstByteStart.Sub(t.connectStart)
+				if timings.TLSHandshake > 0 {
+					// Subtract TLS handshake time to get actual server processing time
+					timings.ServerTime -= timings.TLSHandshake
+				}
+				// Subtract the proxy tunnel negotiation and the request write as well
+				timings.ServerTime -= timings.ProxyConnect + t.requestWrite()
+			case !t.quicStart.IsZero():
+				timings.ServerTime = t.firstByteStart.Sub(t.quicStart) - timings.QUICHandshake - t.requestWrite()
+			default:
+				return
+			}
+			progress.Update("response_first_byte", "completed", timings.ServerTime)
+		},
+	}
+}
@@ -0,0 +1,66 @@
+package http
+
+import (
+	"io"
+	"time"
+)
+
+// transferReportInterval limits how often the byte progress of a transfer is reported
+const transferReportInterval = 100 * time.Millisecond
+
+// transferCounter is an io.Writer counting the bytes of a body transfer and
+// reporting them to the progress observer when it follows transfers.
+// Direction is download or upload.
+type transferCounter struct {
+	observer   TransferObserver
+	direction  string
+	total      int64
+	count      int64
+	lastReport time.Time
+}
+
+// newTransferCounter creates a counter for a transfer of total bytes, -1 when unknown
+func newTransferCounter(progress ProgressObserver, direction string, total int64) *transferCounter {
+	observer, _ := progress.(TransferObserver)
+	return &transferCounter{observer: observer, direction: direction, total: total}
+}
+
+func (t *transferCounter) Write(p []byte) (int, error) {
+	t.count += int64(len(p))
+	if t.observer != nil && time.Since(t.lastReport) >= transferReportInterval {
+		t.lastReport = time.Now()
+		t.observer.TransferProgress(t.direction, t.count, t.total)
+	}
+	return len(p), nil
+}
+
+// done reports the final byte count
+func (t *transferCounter) done() {
+	if t.observer != nil {
+		t.observer.TransferProgress(t.direction, t.count, t.total)
+	}
+}
+
+// uploadBody wraps a request body to report the bytes sent to the transfer counter
+type uploadBody struct {
+	body    io.ReadCloser
+	counter *transferCounter
+}
+
+// newUploadBody wraps body so reading it reports the upload progress
+func newUploadBody(body io.ReadCloser, counter *transferCounter) *uploadBody {
+	return &uploadBody{body: body, counter: counter}
+}
+
+func (u *uploadBody) Read(p []byte) (int, error) {
+	n, err := u.body.Read(p)
+	u.counter.Write(p[:n])
+	if err == io.EOF {
+		u.counter.done()
+	}
+	return n, err
+}
+
+func (u *uploadBody) Close() error {
+	return u.body.Close()
+}
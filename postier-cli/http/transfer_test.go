@@ -0,0 +1,201 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// transferRecorder is a progress observer keeping the last byte count of each direction
+type transferRecorder struct {
+	noopProgress
+
+	mu   sync.Mutex
+	last map[string][2]int64 // Direction to done and total
+}
+
+func (r *transferRecorder) TransferProgress(direction string, done, total int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.last == nil {
+		r.last = make(map[string][2]int64)
+	}
+	r.last[direction] = [2]int64{done, total}
+}
+
+func (r *transferRecorder) report(direction string) (done, total int64, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	last, ok := r.last[direction]
+	return last[0], last[1], ok
+}
+
+func TestDownloadToOutput(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 64*1024) // 1 MiB
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+		for i := 0; i < len(payload); i += 32 * 1024 {
+			w.Write(payload[i : i+32*1024])
+		}
+	}))
+	defer server.Close()
+
+	progress := &transferRecorder{}
+	client, err := NewClient(WithProgress(progress))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output bytes.Buffer
+	resp, err := client.Do(&Request{Method: "GET", URL: server.URL, Output: &output})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Body != "" || !bytes.Equal(output.Bytes(), payload) {
+		t.Errorf("got body %d bytes and output %d bytes, want the output only", len(resp.Body), output.Len())
+	}
+	size := int64(len(payload))
+	if resp.BodySize != size || resp.WireSize != size || resp.ContentLength != size {
+		t.Errorf("got body %d, wire %d, content length %d, want %d", resp.BodySize, resp.WireSize, resp.ContentLength, size)
+	}
+	if done, total, ok := progress.report("download"); !ok || done != size || total != size {
+		t.Errorf("got download progress %d/%d, want %d/%d", done, total, size, size)
+	}
+}
+
+func TestDownloadRetriedBodyNotWritten(t *testing.T) {
+	server, _ := flakyServer(t, http.StatusServiceUnavailable)
+	client, err := NewClient(WithRetryPolicy(RetryPolicy{Retries: 1, Delay: time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output bytes.Buffer
+	resp, err := client.Do(&Request{Method: "GET", URL: server.URL, Output: &output})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The body of the 503 answer is dropped, only the final one reaches the output
+	if output.String() != "done" || resp.StatusCode != http.StatusOK {
+		t.Errorf("got %d with output %q, want 200 with done", resp.StatusCode, output.String())
+	}
+}
+
+func TestDownloadInterruptedNotRetried(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		// The connection ends before the announced length
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer server.Close()
+
+	client, err := NewClient(WithRetryPolicy(RetryPolicy{Retries: 2, Delay: time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output bytes.Buffer
+	if _, err := client.Do(&Request{Method: "GET", URL: server.URL, Output: &output}); err == nil {
+		t.Fatal("interrupted download reported as a success")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// Part of the body is already in the output, sending the request again would duplicate it
+	if requests != 1 || output.String() != "partial" {
+		t.Errorf("got %d requests with output %q, want 1 with partial", requests, output.String())
+	}
+}
+
+// uploadServer answers with the size, length and transfer encoding of the body it received
+func uploadServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/upload", http.StatusTemporaryRedirect)
+			return
+		}
+		n, _ := io.Copy(io.Discard, r.Body)
+		fmt.Fprintf(w, "%d %d %v", n, r.ContentLength, r.TransferEncoding)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestUploadFromFile(t *testing.T) {
+	server := uploadServer(t)
+	file := filepath.Join(t.TempDir(), "upload.bin")
+	const size = 4 << 20
+	if err := os.WriteFile(file, bytes.Repeat([]byte{'x'}, size), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/upload", "/redirect"} {
+		t.Run(path, func(t *testing.T) {
+			progress := &transferRecorder{}
+			client, err := NewClient(WithProgress(progress))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(&Request{Method: "PUT", URL: server.URL + path, Body: "@" + file, BodyType: "text"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The file is sent with its size, and sent again in full after a 307
+			if want := fmt.Sprintf("%d %d []", size, size); resp.Body != want {
+				t.Errorf("server got %q, want %q", resp.Body, want)
+			}
+			if done, total, ok := progress.report("upload"); !ok || done != size || total != size {
+				t.Errorf("got upload progress %d/%d, want %d/%d", done, total, size, size)
+			}
+			if resp.Timings.RequestWrite <= 0 {
+				t.Errorf("request write time not recorded")
+			}
+		})
+	}
+}
+
+func TestUploadUnknownSize(t *testing.T) {
+	server := uploadServer(t)
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		writer.Write([]byte("streamed from a pipe"))
+		writer.Close()
+	}()
+	stdin := os.Stdin
+	os.Stdin = reader
+	defer func() { os.Stdin = stdin }()
+
+	progress := &transferRecorder{}
+	client, err := NewClient(WithProgress(progress), WithRetryPolicy(RetryPolicy{Retries: 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(&Request{Method: "PUT", URL: server.URL, Body: "@-", BodyType: "text"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The standard input has no known size, it is sent chunked
+	if resp.Body != "20 -1 [chunked]" {
+		t.Errorf("server got %q, want a chunked body of 20 bytes", resp.Body)
+	}
+	if done, total, _ := progress.report("upload"); done != 20 || total != -1 {
+		t.Errorf("got upload progress %d/%d, want 20/-1", done, total)
+	}
+}
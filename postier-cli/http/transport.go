@@ -0,0 +1,178 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+
+	"golang.org/x/net/http2"
+)
+
+// HTTP versions accepted by WithHTTPVersion
+const (
+	HTTPVersionAuto = "auto" // HTTP/2 when the server offers it with ALPN, HTTP/1.1 otherwise
+	HTTPVersion11   = "1.1"  // HTTP/1.1 only
+	HTTPVersion2    = "2"    // HTTP/2 over TLS, fails when the server does not negotiate it
+	HTTPVersionH2C  = "h2c"  // HTTP/2 over cleartext TCP with prior knowledge
+	HTTPVersion3    = "3"    // HTTP/3 over QUIC
+)
+
+// WithHTTPVersion selects the HTTP protocol version used for requests
+func WithHTTPVersion(version string) Option {
+	return func(c *Client) {
+		c.httpVersion = version
+	}
+}
+
+// WithUnixSocket sends requests over the Unix domain socket at path instead of a TCP
+// connection, the URL host is only used for the Host header and TLS server name
+func WithUnixSocket(path string) Option {
+	return func(c *Client) {
+		c.unixSocket = path
+	}
+}
+
+// newTransport builds the default transport from the client options.
+// Response decoding is left to the client, which measures the body before it.
+func (c *Client) newTransport() (http.RoundTripper, error) {
+	tlsConfig, err := c.newTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.initResolver(); err != nil {
+		return nil, err
+	}
+
+	proxyFunc, err := c.newProxyFunc()
+	if err != nil {
+		return nil, err
+	}
+	if c.proxyURL != "" && (c.httpVersion == HTTPVersionH2C || c.httpVersion == HTTPVersion3) {
+		return nil, fmt.Errorf("proxies are not supported with HTTP version %s", c.httpVersion)
+	}
+	if c.unixSocket != "" {
+		if c.proxyURL != "" {
+			return nil, fmt.Errorf("proxies are not supported over a Unix socket")
+		}
+		if c.httpVersion == HTTPVersion3 {
+			return nil, fmt.Errorf("HTTP/3 is not supported over a Unix socket")
+		}
+		// The socket is local, proxies from the environment do not apply
+		proxyFunc = nil
+	}
+
+	switch c.httpVersion {
+	case "", HTTPVersionAuto, HTTPVersion2:
+		return &http.Transport{
+			Proxy:                  c.transportProxy(proxyFunc),
+			OnProxyConnectResponse: onProxyConnectResponse,
+			DialContext:            c.dialContext,
+			TLSClientConfig:        tlsConfig,
+			TLSHandshakeTimeout:    c.tlsTimeout,
+			ResponseHeaderTimeout:  c.responseHeaderTimeout,
+			DisableCompression:     true,
+			ForceAttemptHTTP2:      true,
+		}, nil
+	case HTTPVersion11:
+		tlsConfig.NextProtos = []string{"http/1.1"}
+		return &http.Transport{
+			Proxy:                  c.transportProxy(proxyFunc),
+			OnProxyConnectResponse: onProxyConnectResponse,
+			DialContext:            c.dialContext,
+			TLSClientConfig:        tlsConfig,
+			TLSHandshakeTimeout:    c.tlsTimeout,
+			ResponseHeaderTimeout:  c.responseHeaderTimeout,
+			DisableCompression:     true,
+			// A non-nil empty map disables the automatic HTTP/2 upgrade
+			TLSNextProto: map[string]func(string, *tls.Conn) http.RoundTripper{},
+		}, nil
+	case HTTPVersionH2C:
+		return c.withHeaderTimeout(&http2.Transport{
+			AllowHTTP:          true,
+			TLSClientConfig:    tlsConfig,
+			DisableCompression: true,
+			// Prior knowledge: speak HTTP/2 directly over the TCP connection
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return c.dialDirect(ctx, network, addr)
+			},
+		}), nil
+	case HTTPVersion3:
+		return c.withHeaderTimeout(c.newHTTP3Transport(tlsConfig)), nil
+	default:
+		return nil, fmt.Errorf("unsupported HTTP version: %s", c.httpVersion)
+	}
+}
+
+// withHeaderTimeout applies the response header timeout to a transport that has no such setting
+func (c *Client) withHeaderTimeout(transport http.RoundTripper) http.RoundTripper {
+	if c.responseHeaderTimeout <= 0 {
+		return transport
+	}
+	return &headerTimeoutTransport{next: transport, timeout: c.responseHeaderTimeout}
+}
+
+// closeIdleConnections closes the idle connections of a transport that keeps some
+func closeIdleConnections(transport http.RoundTripper) {
+	if closer, ok := transport.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// dialContext opens the connection to the server or to the proxy, through a SOCKS proxy when one was selected
+func (c *Client) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	trace := contextRequestTrace(ctx)
+	if socksProxy := trace.socksProxy(); socksProxy != nil {
+		return c.dialSOCKS(ctx, socksProxy, network, addr)
+	}
+	if trace.httpProxy() != nil {
+		// The transport dials the HTTP proxy, the host overrides only apply to the server
+		return c.dialProxy(ctx, network, addr)
+	}
+	return c.dialDirect(ctx, network, addr)
+}
+
+// dialDirect opens a TCP connection to the server at addr, or a connection to the Unix socket when one is set.
+// The host overrides are applied here so the DNS and connect phases match what is dialed.
+func (c *Client) dialDirect(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: c.connectTimeout, Resolver: c.resolver}
+	if c.unixSocket != "" {
+		// No DNS lookup happens, the DNS phase is skipped
+		return dialer.DialContext(ctx, "unix", c.unixSocket)
+	}
+
+	network = c.ipNetwork(network)
+	addr = c.applyConnectTo(addr)
+	pinned, err := c.pinnedAddrs(addr)
+	if err != nil {
+		return nil, err
+	}
+	if pinned != nil {
+		// Pinned addresses are dialed in turn without any DNS lookup
+		for _, pinnedAddr := range pinned {
+			var conn net.Conn
+			if conn, err = dialer.DialContext(ctx, network, pinnedAddr); err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// dialProxy opens a TCP connection to the proxy at addr, with the IP version and
+// DNS servers of the client but without the host overrides, which target the server
+func (c *Client) dialProxy(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: c.connectTimeout, Resolver: c.resolver}
+	return dialer.DialContext(ctx, c.ipNetwork(network), addr)
+}
+
+// checkProtocol verifies that the negotiated protocol matches the requested HTTP version
+func (c *Client) checkProtocol(resp *http.Response) error {
+	if c.httpVersion == HTTPVersion2 && resp.ProtoMajor != 2 {
+		return fmt.Errorf("server did not negotiate HTTP/2, got %s", resp.Proto)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// protoHandler answers with the protocol of the request
+var protoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(r.Proto))
+})
+
+func TestHTTPVersion(t *testing.T) {
+	h2Server := httptest.NewUnstartedServer(protoHandler)
+	h2Server.EnableHTTP2 = true
+	h2Server.StartTLS()
+	defer h2Server.Close()
+	h1Server := httptest.NewTLSServer(protoHandler)
+	defer h1Server.Close()
+	h2cServer := httptest.NewServer(h2c.NewHandler(protoHandler, &http2.Server{}))
+	defer h2cServer.Close()
+
+	for _, test := range []struct {
+		name    string
+		version string
+		url     string
+		want    string // Protocol of the response, or the error when it starts with "error: "
+	}{
+		{"auto offered h2", HTTPVersionAuto, h2Server.URL, "HTTP/2.0"},
+		{"auto without h2", HTTPVersionAuto, h1Server.URL, "HTTP/1.1"},
+		{"1.1 offered h2", HTTPVersion11, h2Server.URL, "HTTP/1.1"},
+		{"2", HTTPVersion2, h2Server.URL, "HTTP/2.0"},
+		{"2 without h2", HTTPVersion2, h1Server.URL, "error: server did not negotiate HTTP/2"},
+		{"h2c", HTTPVersionH2C, h2cServer.URL, "HTTP/2.0"},
+		{"1.1 to h2c server", HTTPVersion11, h2cServer.URL, "HTTP/1.1"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := NewClient(WithHTTPVersion(test.version), WithInsecure(true))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(&Request{Method: "GET", URL: test.url})
+			if wantErr, ok := strings.CutPrefix(test.want, "error: "); ok {
+				if err == nil || !strings.Contains(err.Error(), wantErr) {
+					t.Errorf("got %v, want an error with %q", err, wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The server saw the same protocol as the client
+			if resp.Protocol != test.want || resp.Body != test.want {
+				t.Errorf("got %s, server saw %s, want %s", resp.Protocol, resp.Body, test.want)
+			}
+		})
+	}
+}
+
+func TestHTTPVersionInvalid(t *testing.T) {
+	for _, test := range []struct {
+		opts    []Option
+		wantErr string
+	}{
+		{[]Option{WithHTTPVersion("1.0")}, "unsupported HTTP version: 1.0"},
+		{[]Option{WithHTTPVersion(HTTPVersionH2C), WithProxy("http://proxy.internal:3128")}, "proxies are not supported with HTTP version h2c"},
+	} {
+		if _, err := NewClient(test.opts...); err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("got %v, want an error with %q", err, test.wantErr)
+		}
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host + " " + r.URL.Path))
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	client, err := NewClient(WithUnixSocket(socket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(&Request{Method: "GET", URL: "http://api.local/items"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The URL host only names the server in the Host header
+	if resp.Body != "api.local /items" || resp.UnixSocket != socket {
+		t.Errorf("got %q over %q, want api.local /items over %s", resp.Body, resp.UnixSocket, socket)
+	}
+	if resp.Timings.DNSLookup != 0 {
+		t.Errorf("got DNS lookup %s over a Unix socket", resp.Timings.DNSLookup)
+	}
+}
+
+func TestUnixSocketInvalid(t *testing.T) {
+	for _, test := range []struct {
+		opts    []Option
+		wantErr string
+	}{
+		{[]Option{WithUnixSocket("/run/api.sock"), WithProxy("http://proxy.internal:3128")}, "proxies are not supported over a Unix socket"},
+		{[]Option{WithUnixSocket("/run/api.sock"), WithHTTPVersion(HTTPVersion3)}, "HTTP/3 is not supported over a Unix socket"},
+	} {
+		if _, err := NewClient(test.opts...); err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("got %v, want an error with %q", err, test.wantErr)
+		}
+	}
+}
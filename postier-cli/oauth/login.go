@@ -0,0 +1,188 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	nethttp "net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// loginTimeout is how long a login waits for the browser to come back with the code
+const loginTimeout = 5 * time.Minute
+
+// callbackResult is what the authorization server sent to the loopback redirect
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// Login runs the authorization code flow with PKCE (RFC 7636). The user opens the
+// authorization URL passed to show, the server sends the browser back to a loopback
+// listener (RFC 8252) with a code, and the code is exchanged for tokens that are cached.
+// Later requests with the profile use the cached token and renew it with its refresh token.
+func (s *TokenSource) Login(ctx context.Context, show func(authURL string)) (*Token, error) {
+	if s.profile.AuthURL == "" {
+		return nil, fmt.Errorf("OAuth2 profile '%s' needs an auth_url to log in", s.name)
+	}
+	authURL, err := url.Parse(s.profile.AuthURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth_url: %w", err)
+	}
+
+	listener, redirectURL, err := listenLoopback(s.profile.RedirectURL)
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+
+	verifier, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	state, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", s.profile.ClientID)
+	query.Set("redirect_uri", redirectURL.String())
+	query.Set("state", state)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	if len(s.profile.Scopes) > 0 {
+		query.Set("scope", strings.Join(s.profile.Scopes, " "))
+	}
+	if s.profile.Audience != "" {
+		query.Set("audience", s.profile.Audience)
+	}
+	authURL.RawQuery = query.Encode()
+
+	results := make(chan callbackResult, 1)
+	server := &nethttp.Server{
+		Handler:           callbackHandler(redirectURL.Path, state, results),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	show(authURL.String())
+
+	ctx, cancel := context.WithTimeout(ctx, loginTimeout)
+	defer cancel()
+	var result callbackResult
+	select {
+	case result = <-results:
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("no answer from the authorization server after %s", loginTimeout)
+		}
+		return nil, ctx.Err()
+	}
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	token, err := s.request(ctx, []string{
+		"grant_type=authorization_code",
+		"code=" + result.code,
+		"redirect_uri=" + redirectURL.String(),
+		"code_verifier=" + verifier,
+	}, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange the authorization code: %w", err)
+	}
+	if err := SaveToken(s.name, s.profile, token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// listenLoopback listens on the loopback redirect URI of the profile, or on a free port
+func listenLoopback(redirect string) (net.Listener, *url.URL, error) {
+	if redirect == "" {
+		redirect = "http://127.0.0.1:0/callback"
+	}
+	redirectURL, err := url.Parse(redirect)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid redirect_url: %w", err)
+	}
+	host := redirectURL.Hostname()
+	if redirectURL.Scheme != "http" || (host != "localhost" && !net.ParseIP(host).IsLoopback()) {
+		return nil, nil, fmt.Errorf("redirect_url must be a loopback http address, e.g. http://127.0.0.1:8085/callback")
+	}
+	if redirectURL.Path == "" {
+		redirectURL.Path = "/"
+	}
+
+	address := redirectURL.Host
+	if redirectURL.Port() == "" {
+		address = net.JoinHostPort(host, "80")
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to listen for the redirect: %w", err)
+	}
+	// The free port picked by the system goes in the redirect URI
+	if redirectURL.Port() == "0" {
+		port := listener.Addr().(*net.TCPAddr).Port
+		redirectURL.Host = net.JoinHostPort(host, fmt.Sprint(port))
+	}
+	return listener, redirectURL, nil
+}
+
+// callbackHandler receives the redirect of the browser and passes on its code or error once.
+// A request to another path or with another state is answered with 400 and ignored, the login
+// keeps waiting for the callback of the authorization server it started.
+func callbackHandler(path, state string, results chan<- callbackResult) nethttp.Handler {
+	return nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != path || query.Get("state") != state {
+			nethttp.Error(w, "Not the callback of this login", nethttp.StatusBadRequest)
+			return
+		}
+
+		var result callbackResult
+		switch {
+		case query.Get("error") != "":
+			result.err = fmt.Errorf("authorization denied: %s", query.Get("error"))
+			if description := query.Get("error_description"); description != "" {
+				result.err = fmt.Errorf("authorization denied: %s: %s", query.Get("error"), description)
+			}
+		case query.Get("code") == "":
+			result.err = fmt.Errorf("the authorization server answered without a code")
+		default:
+			result.code = query.Get("code")
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if result.err != nil {
+			w.WriteHeader(nethttp.StatusBadRequest)
+			fmt.Fprintf(w, "Login failed: %s\n", result.err)
+		} else {
+			fmt.Fprintln(w, "Login complete, you can close this window and go back to postier.")
+		}
+		select {
+		case results <- result:
+		default:
+		}
+	})
+}
+
+// randomString returns n random bytes encoded in base64url, as used for the PKCE verifier and the state
+func randomString(n int) (string, error) {
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,221 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// authServer is a stand-in authorization server: /authorize sends the browser back to the
+// redirect URI with a code, /token exchanges the code when the PKCE verifier matches
+type authServer struct {
+	*httptest.Server
+	deny string // error sent back by /authorize instead of a code
+
+	mu        sync.Mutex
+	authorize url.Values // Query of the authorization request
+	verifier  string     // code_verifier of the token request
+}
+
+func newAuthServer(t *testing.T) *authServer {
+	t.Helper()
+	as := &authServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		as.mu.Lock()
+		as.authorize = query
+		as.mu.Unlock()
+
+		callback := url.Values{"state": {query.Get("state")}}
+		if as.deny != "" {
+			callback.Set("error", as.deny)
+			callback.Set("error_description", "the user said no")
+		} else {
+			callback.Set("code", "code-1")
+		}
+		http.Redirect(w, r, query.Get("redirect_uri")+"?"+callback.Encode(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		as.mu.Lock()
+		as.verifier = r.PostForm.Get("code_verifier")
+		authorize := as.authorize
+		as.mu.Unlock()
+
+		challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("code") != "code-1" ||
+			r.PostForm.Get("redirect_uri") != authorize.Get("redirect_uri") ||
+			base64.RawURLEncoding.EncodeToString(challenge[:]) != authorize.Get("code_challenge") {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "token-1",
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+			"refresh_token": "refresh-1",
+		})
+	})
+	as.Server = httptest.NewServer(mux)
+	t.Cleanup(as.Close)
+	return as
+}
+
+func (as *authServer) profile() *Profile {
+	return &Profile{
+		TokenURL: as.URL + "/token",
+		AuthURL:  as.URL + "/authorize?prompt=consent",
+		ClientID: "client",
+		Scopes:   []string{"read", "write"},
+		Grant:    GrantAuthorizationCode,
+	}
+}
+
+// strayCallbacks sends requests to the loopback listener that are not the callback of the
+// login, before the browser follows the authorization URL. The status codes are returned.
+func strayCallbacks(t *testing.T, authURL string) []int {
+	t.Helper()
+	parsed, _ := url.Parse(authURL)
+	redirect, _ := url.Parse(parsed.Query().Get("redirect_uri"))
+	var statuses []int
+	for _, stray := range []string{
+		redirect.String() + "?code=forged&state=forged",
+		redirect.String() + "?code=forged",
+		redirect.Scheme + "://" + redirect.Host + "/favicon.ico",
+	} {
+		resp, err := http.Get(stray)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		resp.Body.Close()
+		statuses = append(statuses, resp.StatusCode)
+	}
+	return statuses
+}
+
+// browse follows the authorization URL like the browser of the user, in the background
+func browse(t *testing.T, authURL string, stray bool, done chan<- []int) {
+	go func() {
+		var statuses []int
+		if stray {
+			statuses = strayCallbacks(t, authURL)
+		}
+		resp, err := http.Get(authURL)
+		if err != nil {
+			t.Error(err)
+		} else {
+			resp.Body.Close()
+			statuses = append(statuses, resp.StatusCode)
+		}
+		done <- statuses
+	}()
+}
+
+func TestLogin(t *testing.T) {
+	useTempConfig(t)
+	as := newAuthServer(t)
+	profile := as.profile()
+	source := newTestTokenSource(t, nil, profile)
+
+	done := make(chan []int, 1)
+	token, err := source.Login(context.Background(), func(authURL string) {
+		browse(t, authURL, true, done)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "token-1" || token.RefreshToken != "refresh-1" {
+		t.Errorf("got %+v, want token-1 and refresh-1", token)
+	}
+
+	// The stray requests got 400 and the login went on with the real callback
+	if statuses := <-done; len(statuses) != 4 || statuses[0] != 400 || statuses[1] != 400 || statuses[2] != 400 || statuses[3] != 200 {
+		t.Errorf("got callback statuses %v, want 400 for the stray requests and 200 for the login", statuses)
+	}
+
+	as.mu.Lock()
+	authorize, verifier := as.authorize, as.verifier
+	as.mu.Unlock()
+	for key, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             "client",
+		"code_challenge_method": "S256",
+		"scope":                 "read write",
+		"prompt":                "consent",
+	} {
+		if got := authorize.Get(key); got != want {
+			t.Errorf("got %s=%q in the authorization URL, want %q", key, got, want)
+		}
+	}
+	// RFC 7636 asks for a verifier of 43 to 128 characters
+	if len(verifier) < 43 || len(verifier) > 128 || len(authorize.Get("state")) == 0 {
+		t.Errorf("got verifier %q and state %q", verifier, authorize.Get("state"))
+	}
+	if !strings.HasPrefix(authorize.Get("redirect_uri"), "http://127.0.0.1:") {
+		t.Errorf("got redirect_uri %q, want a loopback address", authorize.Get("redirect_uri"))
+	}
+
+	// The token is cached for the next commands
+	if cached, err := LoadToken("test", profile); err != nil || cached == nil || cached.AccessToken != "token-1" {
+		t.Errorf("got cached token %+v, %v", cached, err)
+	}
+}
+
+func TestLoginDenied(t *testing.T) {
+	useTempConfig(t)
+	as := newAuthServer(t)
+	as.deny = "access_denied"
+	source := newTestTokenSource(t, nil, as.profile())
+
+	done := make(chan []int, 1)
+	_, err := source.Login(context.Background(), func(authURL string) {
+		browse(t, authURL, false, done)
+	})
+	if err == nil || err.Error() != "authorization denied: access_denied: the user said no" {
+		t.Errorf("got %v, want the error of the authorization server", err)
+	}
+	<-done
+}
+
+func TestLoginWaitsForItsCallback(t *testing.T) {
+	useTempConfig(t)
+	as := newAuthServer(t)
+	source := newTestTokenSource(t, nil, as.profile())
+
+	// Only stray requests come back, the login waits until its deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	done := make(chan []int, 1)
+	_, err := source.Login(ctx, func(authURL string) {
+		go func() { done <- strayCallbacks(t, authURL) }()
+	})
+	if err == nil || !strings.Contains(err.Error(), "no answer from the authorization server") {
+		t.Errorf("got %v, want no answer before the deadline", err)
+	}
+	<-done
+}
+
+func TestListenLoopbackInvalid(t *testing.T) {
+	for _, redirect := range []string{
+		"https://127.0.0.1:8085/callback",
+		"http://example.com:8085/callback",
+		"http://10.0.0.1:8085/callback",
+	} {
+		if listener, _, err := listenLoopback(redirect); err == nil {
+			listener.Close()
+			t.Errorf("%s: got no error, want a loopback address error", redirect)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Grants a profile gets its tokens with, see Profile.Grant
+const (
+	GrantClientCredentials = "client_credentials"
+	GrantRefreshToken      = "refresh_token"
+	GrantAuthorizationCode = "authorization_code" // Tokens come from postier auth login and are then refreshed
+)
+
+// Client authentication methods accepted by Profile.ClientAuth
+const (
+	ClientAuthBasic = "basic" // client_secret_basic: the client id and secret in an Authorization header
+	ClientAuthPost  = "post"  // client_secret_post: the client id and secret in the form body
+)
+
+// Profile describes an OAuth2 client and the server issuing its tokens
+type Profile struct {
+	TokenURL     string   `json:"token_url"`
+	AuthURL      string   `json:"auth_url,omitempty"`     // Authorization endpoint used by postier auth login
+	RedirectURL  string   `json:"redirect_url,omitempty"` // Loopback redirect URI registered for the client, any free port when empty
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret,omitempty"` // The secret, or env:VAR and @file to read it
+	Scopes       []string `json:"scopes,omitempty"`
+	Audience     string   `json:"audience,omitempty"`      // Sent as the audience parameter when the server needs it
+	Grant        string   `json:"grant,omitempty"`         // client_credentials, refresh_token or authorization_code, guessed from the other fields when empty
+	ClientAuth   string   `json:"client_auth,omitempty"`   // basic (default) or post
+	RefreshToken string   `json:"refresh_token,omitempty"` // Refresh token obtained elsewhere, or env:VAR and @file
+}
+
+// GetAppDir returns the application directory, where the history is kept too
+func GetAppDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get AppData directory: %w", err)
+	}
+	appDir := filepath.Join(configDir, "com.postier.app")
+	if err := os.MkdirAll(appDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create application directory: %w", err)
+	}
+	return appDir, nil
+}
+
+// GetProfilesFilePath returns the path to the OAuth2 profiles file
+func GetProfilesFilePath() (string, error) {
+	appDir, err := GetAppDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(appDir, "oauth2.json"), nil
+}
+
+// LoadProfiles reads the OAuth2 profiles, a JSON object of profiles by name
+func LoadProfiles() (map[string]Profile, error) {
+	path, err := GetProfilesFilePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]Profile{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OAuth2 profiles: %w", err)
+	}
+
+	var profiles map[string]Profile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("invalid OAuth2 profiles file %s: %w", path, err)
+	}
+	return profiles, nil
+}
+
+// GetProfile returns the OAuth2 profile with this name
+func GetProfile(name string) (*Profile, error) {
+	profiles, err := LoadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	profile, ok := profiles[name]
+	if !ok {
+		path, _ := GetProfilesFilePath()
+		names := make([]string, 0, len(profiles))
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("OAuth2 profile '%s' not found in %s (profiles: %s)", name, path, strings.Join(names, ", "))
+	}
+	if profile.TokenURL == "" || profile.ClientID == "" {
+		return nil, fmt.Errorf("OAuth2 profile '%s' needs a token_url and a client_id", name)
+	}
+	if profile.Grant == "" {
+		switch {
+		case profile.RefreshToken != "":
+			profile.Grant = GrantRefreshToken
+		case profile.AuthURL != "":
+			profile.Grant = GrantAuthorizationCode
+		default:
+			profile.Grant = GrantClientCredentials
+		}
+	}
+	switch profile.Grant {
+	case GrantClientCredentials, GrantRefreshToken, GrantAuthorizationCode:
+	default:
+		return nil, fmt.Errorf("OAuth2 profile '%s': unsupported grant %s", name, profile.Grant)
+	}
+	switch profile.ClientAuth {
+	case "", ClientAuthBasic, ClientAuthPost:
+	default:
+		return nil, fmt.Errorf("OAuth2 profile '%s': unsupported client_auth %s", name, profile.ClientAuth)
+	}
+	return &profile, nil
+}
+
+// fingerprint identifies the settings a token is issued for: the token URL, the client id,
+// the scopes and the audience. A token cached for other settings is not sent.
+func (p *Profile) fingerprint() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{p.TokenURL, p.ClientID, strings.Join(p.Scopes, " "), p.Audience}, "\n")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// resolveSecret reads a secret given as env:VAR or @file, any other value is the secret itself
+func resolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "env:"):
+		name := value[len("env:"):]
+		secret := os.Getenv(name)
+		if secret == "" {
+			return "", fmt.Errorf("environment variable %s is empty", name)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, "@"):
+		data, err := os.ReadFile(value[1:])
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return value, nil
+}
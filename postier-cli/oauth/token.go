@@ -0,0 +1,279 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bouteillerAlan/postier/http"
+)
+
+// expiryMargin is how long before its expiry a cached token is renewed
+const expiryMargin = 30 * time.Second
+
+// Token is an access token cached on disk with the refresh token issued with it
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"` // Zero when the server did not say
+	Fingerprint  string    `json:"fingerprint"`      // Settings of the profile the token was issued for, see Profile.fingerprint
+}
+
+// valid reports whether the token can still be sent
+func (t *Token) valid() bool {
+	return t != nil && t.AccessToken != "" && (t.Expiry.IsZero() || time.Until(t.Expiry) > expiryMargin)
+}
+
+// GetTokensFilePath returns the path to the token cache
+func GetTokensFilePath() (string, error) {
+	appDir, err := GetAppDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(appDir, "tokens.json"), nil
+}
+
+// loadTokens reads the token cache, a JSON object of tokens by profile name
+func loadTokens() (map[string]*Token, error) {
+	path, err := GetTokensFilePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]*Token{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token cache: %w", err)
+	}
+
+	tokens := make(map[string]*Token)
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("invalid token cache %s: %w", path, err)
+	}
+	return tokens, nil
+}
+
+// LoadToken returns the cached token of a profile, nil when there is none or when it was
+// issued before the token URL, client id, scopes or audience of the profile changed
+func LoadToken(name string, profile *Profile) (*Token, error) {
+	tokens, err := loadTokens()
+	if err != nil {
+		return nil, err
+	}
+	token := tokens[name]
+	if token == nil || token.Fingerprint != profile.fingerprint() {
+		return nil, nil
+	}
+	return token, nil
+}
+
+// SaveToken caches the token of a profile, nil removes it. The cache is readable
+// by its owner only and replaced atomically.
+func SaveToken(name string, profile *Profile, token *Token) error {
+	tokens, err := loadTokens()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		delete(tokens, name)
+	} else {
+		token.Fingerprint = profile.fingerprint()
+		tokens[name] = token
+	}
+
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode token cache: %w", err)
+	}
+	path, err := GetTokensFilePath()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tokens-*")
+	if err != nil {
+		return fmt.Errorf("failed to save token cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err == nil {
+		_, err = tmp.Write(data)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save token cache: %w", err)
+	}
+	return nil
+}
+
+// TokenSource supplies the access tokens of a profile: the cached one while it is valid,
+// then a new one from the refresh token or the client credentials grant
+type TokenSource struct {
+	name    string
+	profile *Profile
+	client  *http.Client // Sends the token requests
+
+	mu sync.Mutex
+}
+
+// NewTokenSource returns the token source of a profile, its token requests are sent with client
+func NewTokenSource(name string, profile *Profile, client *http.Client) *TokenSource {
+	return &TokenSource{name: name, profile: profile, client: client}
+}
+
+// Token returns a valid access token, refresh asks for a new one even when the cached
+// token has not expired, after the server rejected it
+func (s *TokenSource) Token(ctx context.Context, refresh bool) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cached, err := LoadToken(s.name, s.profile)
+	if err != nil {
+		return "", err
+	}
+	if cached.valid() && !refresh {
+		return cached.AccessToken, nil
+	}
+
+	token, err := s.fetch(ctx, cached)
+	if err != nil {
+		return "", err
+	}
+	if err := SaveToken(s.name, s.profile, token); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+	}
+	return token.AccessToken, nil
+}
+
+// fetch gets a new token, with the refresh token when there is one
+func (s *TokenSource) fetch(ctx context.Context, cached *Token) (*Token, error) {
+	// A refresh token of the profile stays there, only the ones issued by the server are cached
+	refreshToken, keep := s.profile.RefreshToken, ""
+	if cached != nil && cached.RefreshToken != "" {
+		refreshToken, keep = cached.RefreshToken, cached.RefreshToken
+	}
+
+	var refreshErr error
+	if refreshToken != "" {
+		refreshToken, err := resolveSecret(refreshToken)
+		if err != nil {
+			return nil, fmt.Errorf("OAuth2 profile '%s': %w", s.name, err)
+		}
+		token, err := s.request(ctx, []string{"grant_type=refresh_token", "refresh_token=" + refreshToken}, keep)
+		if err == nil {
+			return token, nil
+		}
+		refreshErr = err
+	}
+
+	switch {
+	case s.profile.Grant == GrantAuthorizationCode && refreshErr != nil:
+		return nil, fmt.Errorf("OAuth2 profile '%s': failed to refresh the token, log in again with postier auth login %s: %w", s.name, s.name, refreshErr)
+	case s.profile.Grant == GrantAuthorizationCode:
+		return nil, fmt.Errorf("OAuth2 profile '%s' has no token, log in with postier auth login %s", s.name, s.name)
+	case s.profile.Grant == GrantRefreshToken && refreshErr != nil:
+		return nil, fmt.Errorf("OAuth2 profile '%s': failed to refresh the token: %w", s.name, refreshErr)
+	case s.profile.Grant == GrantRefreshToken:
+		return nil, fmt.Errorf("OAuth2 profile '%s' has no refresh token", s.name)
+	}
+	return s.request(ctx, []string{"grant_type=client_credentials"}, "")
+}
+
+// request sends a token request with the grant parameters in form, refreshToken is kept
+// when the server does not issue a new one
+func (s *TokenSource) request(ctx context.Context, form []string, refreshToken string) (*Token, error) {
+	if len(s.profile.Scopes) > 0 {
+		form = append(form, "scope="+strings.Join(s.profile.Scopes, " "))
+	}
+	if s.profile.Audience != "" {
+		form = append(form, "audience="+s.profile.Audience)
+	}
+
+	secret := ""
+	if s.profile.ClientSecret != "" {
+		var err error
+		if secret, err = resolveSecret(s.profile.ClientSecret); err != nil {
+			return nil, fmt.Errorf("OAuth2 profile '%s': %w", s.name, err)
+		}
+	}
+	req := &http.Request{
+		Method:   "POST",
+		URL:      s.profile.TokenURL,
+		Headers:  `{"Accept": "application/json"}`,
+		BodyType: "form",
+	}
+	// Public clients and client_secret_post send their id in the form
+	if secret == "" || s.profile.ClientAuth == ClientAuthPost {
+		form = append(form, "client_id="+s.profile.ClientID)
+		if secret != "" {
+			form = append(form, "client_secret="+secret)
+		}
+	} else {
+		// The client id and secret are form encoded in the basic credentials (RFC 6749 2.3.1)
+		req.Auth = &http.Auth{
+			Type:     http.AuthBasic,
+			Username: url.QueryEscape(s.profile.ClientID),
+			Password: url.QueryEscape(secret),
+		}
+	}
+	req.Form = form
+
+	resp, err := s.client.DoContext(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	return parseTokenResponse(resp, refreshToken)
+}
+
+// parseTokenResponse reads the token issued by the server, or the error it answered with
+func parseTokenResponse(resp *http.Response, refreshToken string) (*Token, error) {
+	var body struct {
+		AccessToken      string      `json:"access_token"`
+		TokenType        string      `json:"token_type"`
+		RefreshToken     string      `json:"refresh_token"`
+		ExpiresIn        json.Number `json:"expires_in"`
+		Error            string      `json:"error"`
+		ErrorDescription string      `json:"error_description"`
+	}
+	decodeErr := json.Unmarshal([]byte(resp.Body), &body)
+
+	if resp.StatusCode != 200 || body.AccessToken == "" {
+		switch {
+		case body.Error != "" && body.ErrorDescription != "":
+			return nil, fmt.Errorf("token endpoint answered %d: %s: %s", resp.StatusCode, body.Error, body.ErrorDescription)
+		case body.Error != "":
+			return nil, fmt.Errorf("token endpoint answered %d: %s", resp.StatusCode, body.Error)
+		case decodeErr != nil:
+			return nil, fmt.Errorf("token endpoint answered %d with an invalid response: %w", resp.StatusCode, decodeErr)
+		}
+		return nil, fmt.Errorf("token endpoint answered %d without an access token", resp.StatusCode)
+	}
+	if body.TokenType != "" && !strings.EqualFold(body.TokenType, "bearer") {
+		return nil, fmt.Errorf("unsupported token type: %s", body.TokenType)
+	}
+
+	token := &Token{AccessToken: body.AccessToken, TokenType: body.TokenType, RefreshToken: body.RefreshToken}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	if body.ExpiresIn != "" {
+		seconds, err := body.ExpiresIn.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid expires_in in token response: %s", body.ExpiresIn)
+		}
+		token.Expiry = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	return token, nil
+}
@@ -0,0 +1,197 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	postierhttp "github.com/bouteillerAlan/postier/http"
+)
+
+// tokenServer is a stand-in token endpoint issuing token-1, token-2... and recording the grants asked
+type tokenServer struct {
+	*httptest.Server
+	expiresIn int // expires_in of the issued tokens
+
+	mu     sync.Mutex
+	issued int
+	grants []string // grant_type of each request, with its refresh token
+}
+
+func newTokenServer(t *testing.T, expiresIn int) *tokenServer {
+	t.Helper()
+	ts := &tokenServer{expiresIn: expiresIn}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, ok := r.BasicAuth(); !ok || id != "client" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		r.ParseForm()
+		ts.mu.Lock()
+		ts.issued++
+		issued := ts.issued
+		grant := r.PostForm.Get("grant_type")
+		if grant == "refresh_token" {
+			grant += ":" + r.PostForm.Get("refresh_token")
+		}
+		ts.grants = append(ts.grants, grant)
+		ts.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  fmt.Sprintf("token-%d", issued),
+			"token_type":    "Bearer",
+			"expires_in":    ts.expiresIn,
+			"refresh_token": fmt.Sprintf("refresh-%d", issued),
+		})
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// requests returns the grants asked so far
+func (ts *tokenServer) requests() []string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return append([]string(nil), ts.grants...)
+}
+
+// newTestTokenSource returns a token source for a profile of ts, the token cache goes to a temporary directory
+func newTestTokenSource(t *testing.T, ts *tokenServer, profile *Profile) *TokenSource {
+	t.Helper()
+	if profile == nil {
+		profile = &Profile{TokenURL: ts.URL, ClientID: "client", ClientSecret: "secret", Grant: GrantClientCredentials}
+	}
+	client, err := postierhttp.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewTokenSource("test", profile, client)
+}
+
+func useTempConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+}
+
+func assertGrants(t *testing.T, ts *tokenServer, want ...string) {
+	t.Helper()
+	got := ts.requests()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got token requests %v, want %v", got, want)
+	}
+}
+
+func TestTokenFetchAndCache(t *testing.T) {
+	useTempConfig(t)
+	ts := newTokenServer(t, 3600)
+
+	token, err := newTestTokenSource(t, ts, nil).Token(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "token-1" {
+		t.Errorf("got %s, want token-1", token)
+	}
+
+	// A new token source, as in the next command, reads the cache
+	token, err = newTestTokenSource(t, ts, nil).Token(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "token-1" {
+		t.Errorf("got %s, want the cached token-1", token)
+	}
+	assertGrants(t, ts, "client_credentials")
+}
+
+func TestTokenRefreshOnExpiry(t *testing.T) {
+	useTempConfig(t)
+	// Tokens expire within the renewal margin, the cached one is never sent again
+	ts := newTokenServer(t, 10)
+
+	for _, want := range []string{"token-1", "token-2"} {
+		token, err := newTestTokenSource(t, ts, nil).Token(context.Background(), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != want {
+			t.Errorf("got %s, want %s", token, want)
+		}
+	}
+	assertGrants(t, ts, "client_credentials", "refresh_token:refresh-1")
+}
+
+func TestTokenCacheFollowsProfile(t *testing.T) {
+	useTempConfig(t)
+	ts := newTokenServer(t, 3600)
+	profile := &Profile{TokenURL: ts.URL, ClientID: "client", ClientSecret: "secret", Grant: GrantClientCredentials}
+
+	if _, err := newTestTokenSource(t, ts, profile).Token(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+	changed := *profile
+	changed.Scopes = []string{"admin"}
+	token, err := newTestTokenSource(t, ts, &changed).Token(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "token-2" {
+		t.Errorf("got %s, want a new token for the new scopes", token)
+	}
+	// The refresh token was issued for the old scopes, it is not used either
+	assertGrants(t, ts, "client_credentials", "client_credentials")
+}
+
+func TestTokenRetryOnUnauthorized(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		accepted string // Token the API accepts, none when empty
+		status   int
+	}{
+		{"renewed token accepted", "token-2", http.StatusOK},
+		{"renewed token rejected", "", http.StatusUnauthorized},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			useTempConfig(t)
+			ts := newTokenServer(t, 3600)
+			var mu sync.Mutex
+			var sent []string
+			api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				sent = append(sent, r.Header.Get("Authorization"))
+				mu.Unlock()
+				if test.accepted == "" || r.Header.Get("Authorization") != "Bearer "+test.accepted {
+					w.WriteHeader(http.StatusUnauthorized)
+				}
+			}))
+			defer api.Close()
+
+			client, err := postierhttp.NewClient()
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(&postierhttp.Request{
+				Method: "GET",
+				URL:    api.URL,
+				Auth:   &postierhttp.Auth{Type: postierhttp.AuthBearer, Tokens: newTestTokenSource(t, ts, nil)},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != test.status {
+				t.Errorf("got %d, want %d", resp.StatusCode, test.status)
+			}
+			// The rejected token is renewed once and the request sent exactly once more
+			if fmt.Sprint(sent) != "[Bearer token-1 Bearer token-2]" {
+				t.Errorf("got requests with %v, want token-1 then token-2", sent)
+			}
+			assertGrants(t, ts, "client_credentials", "refresh_token:refresh-1")
+		})
+	}
+}
@@ -16,16 +16,29 @@ type ProgressState struct {
 	DNSCompleted          bool
 	ConnectStarted        bool
 	ConnectCompleted      bool
+	ProxyStarted          bool
+	ProxyCompleted        bool
 	TLSStarted            bool
 	TLSCompleted          bool
+	QUICStarted           bool
+	QUICCompleted         bool
+	WriteStarted          bool
+	WriteCompleted        bool
 	RequestSent           bool
 	ResponseStarted       bool
 	ResponseCompleted     bool
 	DNSDuration           time.Duration
 	ConnectDuration       time.Duration
+	ProxyDuration         time.Duration
 	TLSDuration           time.Duration
+	QUICDuration          time.Duration
+	WriteDuration         time.Duration
 	ServerProcessDuration time.Duration
 	TransferDuration      time.Duration
+	DownloadedBytes       int64 // Bytes of response body received so far
+	DownloadTotal         int64 // Expected size of the response body, -1 when unknown
+	UploadedBytes         int64 // Bytes of request body sent so far
+	UploadTotal           int64 // Size of the request body, -1 when unknown
 	StartTime             time.Time
 }
 
@@ -34,6 +47,8 @@ type ProgressUpdate struct {
 	Phase    string
 	State    string
 	Duration time.Duration
+	Bytes    int64 // Bytes transferred, for the download and upload phases
+	Total    int64 // Expected bytes, -1 when unknown
 }
 
 // ProgressDisplay manages the interactive display of request progress
@@ -63,7 +78,10 @@ func NewProgressDisplay(enabled bool) *ProgressDisplay {
 	phaseColors := map[string]string{
 		"dns":      "\033[38;5;39m",  // Blue
 		"connect":  "\033[38;5;48m",  // Blue-green
+		"proxy":    "\033[38;5;141m", // Purple
 		"tls":      "\033[38;5;118m", // Green
+		"quic":     "\033[38;5;87m",  // Cyan
+		"write":    "\033[38;5;213m", // Pink
 		"server":   "\033[38;5;226m", // Yellow
 		"transfer": "\033[38;5;208m", // Orange
 	}
@@ -118,7 +136,10 @@ func (pd *ProgressDisplay) getCurrentPhaseDescription() string {
 		{pd.State.ResponseCompleted, "Complete", "transfer"},
 		{pd.State.ResponseStarted, "Content Transfer", "transfer"},
 		{pd.State.RequestSent, "Server Processing", "server"},
+		{pd.State.WriteStarted, "Request Write", "write"},
 		{pd.State.TLSStarted, "TLS Handshake", "tls"},
+		{pd.State.ProxyStarted, "Proxy Connect", "proxy"},
+		{pd.State.QUICStarted, "QUIC Handshake", "quic"},
 		{pd.State.ConnectStarted, "TCP Connection", "connect"},
 		{pd.State.DNSStarted, "DNS Lookup", "dns"},
 	}
@@ -137,7 +158,7 @@ func (pd *ProgressDisplay) renderProgressBar() string {
 	sb.WriteString("[")
 
 	total := int64(0)
-	phases := []string{"dns", "connect", "tls", "server", "transfer"}
+	phases := []string{"dns", "connect", "proxy", "tls", "quic", "write", "server", "transfer"}
 
 	for _, phase := range phases {
 		if width, exists := pd.PhaseWidths[phase]; exists && width > 0 {
@@ -195,9 +216,18 @@ func updatePhaseWidths(pd *ProgressDisplay, totalDuration int64) {
 	if pd.State.ConnectCompleted {
 		pd.PhaseWidths["connect"] = pd.State.ConnectDuration.Milliseconds() * pd.TotalWidth / totalDuration
 	}
+	if pd.State.ProxyCompleted {
+		pd.PhaseWidths["proxy"] = pd.State.ProxyDuration.Milliseconds() * pd.TotalWidth / totalDuration
+	}
 	if pd.State.TLSCompleted {
 		pd.PhaseWidths["tls"] = pd.State.TLSDuration.Milliseconds() * pd.TotalWidth / totalDuration
 	}
+	if pd.State.QUICCompleted {
+		pd.PhaseWidths["quic"] = pd.State.QUICDuration.Milliseconds() * pd.TotalWidth / totalDuration
+	}
+	if pd.State.WriteCompleted {
+		pd.PhaseWidths["write"] = pd.State.WriteDuration.Milliseconds() * pd.TotalWidth / totalDuration
+	}
 	if pd.State.ResponseStarted {
 		pd.PhaseWidths["server"] = pd.State.ServerProcessDuration.Milliseconds() * pd.TotalWidth / totalDuration
 	}
@@ -233,12 +263,30 @@ func displayRealtimeBars(pd *ProgressDisplay) {
 			}
 			return time.Since(pd.StartTimes["connect"])
 		}},
+		{pd.State.ProxyStarted, pd.State.ProxyCompleted, "proxy", "Proxy Connect", func() time.Duration {
+			if pd.State.ProxyCompleted {
+				return pd.State.ProxyDuration
+			}
+			return time.Since(pd.StartTimes["proxy"])
+		}},
 		{pd.State.TLSStarted, pd.State.TLSCompleted, "tls", "TLS Handshake", func() time.Duration {
 			if pd.State.TLSCompleted {
 				return pd.State.TLSDuration
 			}
 			return time.Since(pd.StartTimes["tls"])
 		}},
+		{pd.State.QUICStarted, pd.State.QUICCompleted, "quic", "QUIC Handshake", func() time.Duration {
+			if pd.State.QUICCompleted {
+				return pd.State.QUICDuration
+			}
+			return time.Since(pd.StartTimes["quic"])
+		}},
+		{pd.State.WriteStarted, pd.State.WriteCompleted, "write", "Request Write", func() time.Duration {
+			if pd.State.WriteCompleted {
+				return pd.State.WriteDuration
+			}
+			return time.Since(pd.StartTimes["write"])
+		}},
 		{pd.State.RequestSent, pd.State.ResponseStarted, "server", "Server Process", func() time.Duration {
 			if pd.State.ResponseStarted {
 				return pd.State.ServerProcessDuration
@@ -254,6 +302,22 @@ func displayRealtimeBars(pd *ProgressDisplay) {
 	}
 
 	for _, phase := range phases {
+		// Long uploads and downloads get a byte based bar instead of one block per time slice
+		if phase.started && phase.colorKey == "write" && pd.State.UploadedBytes > 0 {
+			duration := phase.durationFn()
+			fmt.Fprintf(os.Stdout, "  %s%s\033[0m:           %12s %s\n",
+				pd.PhaseColors[phase.colorKey], phase.desc, duration,
+				transferBar(pd, phase.colorKey, pd.State.UploadedBytes, pd.State.UploadTotal, duration))
+			cumulativeDuration += duration
+			continue
+		}
+		if phase.started && phase.colorKey == "transfer" && pd.State.DownloadedBytes > 0 {
+			duration := phase.durationFn()
+			fmt.Fprintf(os.Stdout, "  %s%s\033[0m:           %12s %s\n",
+				pd.PhaseColors[phase.colorKey], phase.desc, duration,
+				transferBar(pd, phase.colorKey, pd.State.DownloadedBytes, pd.State.DownloadTotal, duration))
+			continue
+		}
 		if phase.started {
 			duration := phase.durationFn()
 			blocks := int(math.Ceil(float64(duration) / float64(MsPerBlock)))
@@ -293,9 +357,18 @@ func countActiveLines(pd *ProgressDisplay) int {
 	if pd.State.ConnectStarted {
 		activeLines++
 	}
+	if pd.State.ProxyStarted {
+		activeLines++
+	}
 	if pd.State.TLSStarted {
 		activeLines++
 	}
+	if pd.State.QUICStarted {
+		activeLines++
+	}
+	if pd.State.WriteStarted {
+		activeLines++
+	}
 	if pd.State.RequestSent {
 		activeLines++
 	}
@@ -320,6 +393,8 @@ func calculateTotalPercent(pd *ProgressDisplay) int {
 	totalPercent := 0
 	if pd.State.ResponseCompleted {
 		totalPercent = 100
+	} else if pd.State.ResponseStarted && pd.State.DownloadTotal > 0 {
+		totalPercent = 80 + int(pd.State.DownloadedBytes*19/pd.State.DownloadTotal)
 	} else if pd.State.ResponseStarted {
 		totalPercent = 80 + int((time.Since(pd.StartTimes["transfer"]).Seconds()/5)*20)
 		if totalPercent > 95 {
@@ -330,11 +405,25 @@ func calculateTotalPercent(pd *ProgressDisplay) int {
 		if totalPercent > 80 {
 			totalPercent = 80
 		}
+	} else if pd.State.WriteStarted && pd.State.UploadTotal > 0 {
+		totalPercent = 50 + int(pd.State.UploadedBytes*10/pd.State.UploadTotal)
+	} else if pd.State.WriteStarted {
+		totalPercent = 50
+	} else if pd.State.QUICStarted {
+		totalPercent = 20
+		if pd.State.QUICCompleted {
+			totalPercent = 60
+		}
 	} else if pd.State.TLSStarted {
 		totalPercent = 40
 		if pd.State.TLSCompleted {
 			totalPercent = 60
 		}
+	} else if pd.State.ProxyStarted {
+		totalPercent = 30
+		if pd.State.ProxyCompleted {
+			totalPercent = 40
+		}
 	} else if pd.State.ConnectStarted {
 		totalPercent = 20
 		if pd.State.ConnectCompleted {
@@ -364,12 +453,33 @@ func (pd *ProgressDisplay) handleUpdate(update ProgressUpdate) {
 	case "connect_complete":
 		pd.State.ConnectCompleted = true
 		pd.State.ConnectDuration = update.Duration
+	case "proxy_start":
+		pd.StartTimes["proxy"] = time.Now()
+		pd.State.ProxyStarted = true
+	case "proxy_complete":
+		pd.State.ProxyCompleted = true
+		pd.State.ProxyDuration = update.Duration
 	case "tls_start":
 		pd.StartTimes["tls"] = time.Now()
 		pd.State.TLSStarted = true
 	case "tls_complete":
 		pd.State.TLSCompleted = true
 		pd.State.TLSDuration = update.Duration
+	case "quic_start":
+		pd.StartTimes["quic"] = time.Now()
+		pd.State.QUICStarted = true
+	case "quic_complete":
+		pd.State.QUICCompleted = true
+		pd.State.QUICDuration = update.Duration
+	case "write_start":
+		pd.StartTimes["write"] = time.Now()
+		pd.State.WriteStarted = true
+	case "write_complete":
+		pd.State.WriteCompleted = true
+		pd.State.WriteDuration = update.Duration
+	case "upload_progress":
+		pd.State.UploadedBytes = update.Bytes
+		pd.State.UploadTotal = update.Total
 	case "request_sent":
 		pd.State.RequestSent = true
 		pd.StartTimes["server"] = time.Now()
@@ -380,6 +490,9 @@ func (pd *ProgressDisplay) handleUpdate(update ProgressUpdate) {
 	case "response_complete":
 		pd.State.ResponseCompleted = true
 		pd.State.TransferDuration = update.Duration
+	case "download_progress":
+		pd.State.DownloadedBytes = update.Bytes
+		pd.State.DownloadTotal = update.Total
 	}
 }
 
@@ -388,7 +501,7 @@ func (pd *ProgressDisplay) completeAll() {
 	pd.Bar.Finish()
 	fmt.Fprintln(os.Stdout, "\nHTTP Request Timings:")
 
-	totalDuration := pd.State.DNSDuration + pd.State.ConnectDuration + pd.State.TLSDuration + pd.State.ServerProcessDuration + pd.State.TransferDuration
+	totalDuration := pd.State.DNSDuration + pd.State.ConnectDuration + pd.State.ProxyDuration + pd.State.TLSDuration + pd.State.QUICDuration + pd.State.WriteDuration + pd.State.ServerProcessDuration + pd.State.TransferDuration
 
 	if totalDuration == 0 {
 		totalDuration = time.Millisecond
@@ -396,9 +509,22 @@ func (pd *ProgressDisplay) completeAll() {
 
 	printPhaseSummary(pd, "DNS Lookup", pd.State.DNSDuration, "dns")
 	printPhaseSummary(pd, "TCP Connection", pd.State.ConnectDuration, "connect")
+	printPhaseSummary(pd, "Proxy Connect", pd.State.ProxyDuration, "proxy")
 	printPhaseSummary(pd, "TLS Handshake", pd.State.TLSDuration, "tls")
+	printPhaseSummary(pd, "QUIC Handshake", pd.State.QUICDuration, "quic")
+	printPhaseSummary(pd, "Request Write", pd.State.WriteDuration, "write")
+	if pd.State.UploadedBytes > 0 {
+		fmt.Fprintf(os.Stdout, "  %sUploaded\033[0m:            %12s at %s/s\n",
+			pd.PhaseColors["write"], formatBytes(pd.State.UploadedBytes),
+			formatBytes(throughput(pd.State.UploadedBytes, pd.State.WriteDuration)))
+	}
 	printPhaseSummary(pd, "Server Process", pd.State.ServerProcessDuration, "server")
 	printPhaseSummary(pd, "Content Transfer", pd.State.TransferDuration, "transfer")
+	if pd.State.DownloadedBytes > 0 {
+		fmt.Fprintf(os.Stdout, "  %sDownloaded\033[0m:          %12s at %s/s\n",
+			pd.PhaseColors["transfer"], formatBytes(pd.State.DownloadedBytes),
+			formatBytes(throughput(pd.State.DownloadedBytes, pd.State.TransferDuration)))
+	}
 
 	fmt.Fprintf(os.Stdout, "  %sTotal Duration\033[0m:      %12s\n",
 		"\033[1;36m", totalDuration)
@@ -428,7 +554,10 @@ func (pd *ProgressDisplay) getPreviousDuration(currentPhase string) time.Duratio
 	}{
 		{"dns", pd.State.DNSDuration},
 		{"connect", pd.State.ConnectDuration},
+		{"proxy", pd.State.ProxyDuration},
 		{"tls", pd.State.TLSDuration},
+		{"quic", pd.State.QUICDuration},
+		{"write", pd.State.WriteDuration},
 		{"server", pd.State.ServerProcessDuration},
 		{"transfer", pd.State.TransferDuration},
 	}
@@ -470,7 +599,10 @@ func displayPhase(pd *ProgressDisplay, phase, state string, duration time.Durati
 	}{
 		"dns":      {"DNS Lookup", "dns", func() bool { return state == "complete" }, func() time.Duration { return duration }},
 		"connect":  {"TCP Connection", "connect", func() bool { return state == "complete" }, func() time.Duration { return duration }},
+		"proxy":    {"Proxy Connect", "proxy", func() bool { return state == "complete" }, func() time.Duration { return duration }},
 		"tls":      {"TLS Handshake", "tls", func() bool { return state == "complete" }, func() time.Duration { return duration }},
+		"quic":     {"QUIC Handshake", "quic", func() bool { return state == "complete" }, func() time.Duration { return duration }},
+		"write":    {"Request Write", "write", func() bool { return state == "complete" }, func() time.Duration { return duration }},
 		"server":   {"Server Process", "server", func() bool { return state == "response_first_byte" }, func() time.Duration { return duration }},
 		"transfer": {"Content Transfer", "transfer", func() bool { return state == "response_complete" }, func() time.Duration { return duration }},
 	}
@@ -489,6 +621,20 @@ func displayPhase(pd *ProgressDisplay, phase, state string, duration time.Durati
 	}
 }
 
+// TransferProgress sends the byte progress of a body transfer
+func (pd *ProgressDisplay) TransferProgress(direction string, done, total int64) {
+	if !pd.Enabled {
+		return
+	}
+
+	pd.UpdateChan <- ProgressUpdate{
+		Phase: direction + "_progress",
+		State: "progress",
+		Bytes: done,
+		Total: total,
+	}
+}
+
 // Complete signals that the progress display is complete
 func (pd *ProgressDisplay) Complete() {
 	if !pd.Enabled {
@@ -497,3 +643,53 @@ func (pd *ProgressDisplay) Complete() {
 
 	pd.CompleteChan <- true
 }
+
+// transferBarWidth is the width of the byte based transfer bar
+const transferBarWidth = 30
+
+// transferBar renders the progress of an upload or download with its size, throughput and ETA
+func transferBar(pd *ProgressDisplay, colorKey string, done, total int64, elapsed time.Duration) string {
+	rate := throughput(done, elapsed)
+	color := pd.PhaseColors[colorKey]
+
+	if total <= 0 {
+		// Unknown size: no bar nor ETA
+		return fmt.Sprintf("%s%s\033[0m  %s/s", color, formatBytes(done), formatBytes(rate))
+	}
+
+	filled := int(done * transferBarWidth / total)
+	if filled > transferBarWidth {
+		filled = transferBarWidth
+	}
+	eta := "--"
+	if rate > 0 && done < total {
+		eta = time.Duration(float64(total-done) / float64(rate) * float64(time.Second)).Round(time.Second).String()
+	} else if done >= total {
+		eta = "0s"
+	}
+	return fmt.Sprintf("%s|%s%s|\033[0m %s / %s  %s/s  ETA %s",
+		color, strings.Repeat("\u2588", filled), strings.Repeat(" ", transferBarWidth-filled),
+		formatBytes(done), formatBytes(total), formatBytes(rate), eta)
+}
+
+// throughput returns the bytes per second of a transfer
+func throughput(bytes int64, elapsed time.Duration) int64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return int64(float64(bytes) / elapsed.Seconds())
+}
+
+// formatBytes formats a byte count with a binary unit, e.g. 12.3 MiB
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}